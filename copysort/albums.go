@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// Album mapping: date sorting destroys intentional curation in the source
+// ("2019 Italy Trip/..." becomes 2019/05/14/...). -albums records the
+// folder a media file came from, either as a symlinked albums/<name>/
+// tree in the destination (-albums link) or as a sidecar index file
+// (-albums index), so that curation survives the import.
+
+const albumsIndexName = ".copysort-albums"
+
+var albums struct {
+	mode string // "", "link" or "index"
+
+	mu    sync.Mutex
+	index *os.File
+}
+
+// cameraDirRe matches folder names that are camera plumbing rather than
+// curation: DCIM, 100APPLE, 103CANON, MISC and the like.
+var cameraDirRe = regexp.MustCompile(`^(?i:dcim|misc|private|\d{3}[A-Z0-9_]+)$`)
+
+func initAlbums(mode, destRoot string) error {
+	switch mode {
+	case "":
+		return nil
+	case "link", "index":
+	default:
+		return fmt.Errorf("invalid -albums %q (want link or index)", mode)
+	}
+	albums.mode = mode
+	if mode == "index" && !opts.dryRun {
+		f, err := os.OpenFile(filepath.Join(destRoot, albumsIndexName),
+			os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		albums.index = f
+	}
+	return nil
+}
+
+// albumName derives the album for a source-relative path: the nearest
+// ancestor folder that looks hand-named, or "" when the file sat at the
+// root or only under camera plumbing directories.
+func albumName(rel string) string {
+	// Photos.app originals carry their album in the library database,
+	// not the directory names (which are opaque shards).
+	if title, ok := photosAlbumFor(rel); ok {
+		return title
+	}
+	dir := path.Dir(rel)
+	for dir != "." && dir != "/" {
+		name := path.Base(dir)
+		if !cameraDirRe.MatchString(name) {
+			return name
+		}
+		dir = path.Dir(dir)
+	}
+	return ""
+}
+
+// recordAlbum files one sorted media file under its album.
+func recordAlbum(rel, destRoot, destRel string, st *stats) {
+	name := albumName(rel)
+	if name == "" {
+		return
+	}
+	switch albums.mode {
+	case "index":
+		albums.mu.Lock()
+		_, err := fmt.Fprintf(albums.index, "%s\t%s\n", name, destRel)
+		albums.mu.Unlock()
+		if err != nil {
+			errorf("albums index: %v\n", err)
+			st.fail()
+		}
+	case "link":
+		dir := filepath.Join(destRoot, "albums", name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			errorf("album %s: %v\n", name, err)
+			st.fail()
+			return
+		}
+		link := filepath.Join(dir, path.Base(destRel))
+		target, err := filepath.Rel(dir, filepath.Join(destRoot, filepath.FromSlash(destRel)))
+		if err != nil {
+			target = filepath.Join(destRoot, filepath.FromSlash(destRel))
+		}
+		if err := os.Symlink(target, link); err != nil && !os.IsExist(err) {
+			errorf("album %s: %v\n", name, err)
+			st.fail()
+		}
+	}
+}
+
+func closeAlbums() {
+	if albums.index != nil {
+		albums.index.Close()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Apple export quirks: an iCloud "Download originals" or Photos export
+// re-run drops "IMG_0042 (1).HEIC" next to IMG_0042.HEIC, writes .AAE
+// edit recipes alongside edited assets, and can emit both the HEIC
+// original and a converted JPEG of the same photo. -apple-exports folds
+// all three back into single date-tree entries: numbered duplicates of
+// an identical sibling are dropped, .AAE sidecars travel as companions
+// of their asset, and the JPEG half of a HEIC+JPEG pair is dropped in
+// favor of the original.
+
+// appleDupRe matches the " (1)" style suffix Photos appends to avoid
+// clobbering an earlier export of the same asset.
+var appleDupRe = regexp.MustCompile(`^(.*) \(\d+\)$`)
+
+func appleExportFilter(jobs []job) []job {
+	full := map[string]int{} // dir + lowercase stem + ext -> index into jobs
+	for i := range jobs {
+		full[pairKey(jobs[i].relPath)+strings.ToLower(filepath.Ext(jobs[i].relPath))] = i
+	}
+	dropped := map[int]bool{}
+	for i := range jobs {
+		ext := strings.ToLower(filepath.Ext(jobs[i].relPath))
+		key := pairKey(jobs[i].relPath)
+		// "X (1).ext" next to an identically sized X.ext is a re-export
+		// of the same asset.
+		if m := appleDupRe.FindStringSubmatch(key); m != nil {
+			if oi, ok := full[m[1]+ext]; ok && oi != i && jobs[oi].size == jobs[i].size {
+				dropped[i] = true
+				continue
+			}
+		}
+		// HEIC+JPEG of the same asset: keep the original, drop the
+		// conversion.
+		if ext == ".jpg" || ext == ".jpeg" {
+			if oi, ok := full[key+".heic"]; !ok || dropped[oi] {
+				oi, ok = full[key+".heif"]
+				if !ok || dropped[oi] {
+					continue
+				}
+			}
+			dropped[i] = true
+		}
+	}
+	// .AAE edit recipes pair with their asset like a Live Photo .mov
+	// does, so edits survive a round-trip back into Photos. Photos
+	// names the sidecar of an edited IMG_0042 either IMG_0042.AAE or
+	// IMG_O0042.AAE depending on version.
+	for i := range jobs {
+		if strings.ToLower(filepath.Ext(jobs[i].relPath)) != ".aae" {
+			continue
+		}
+		keys := []string{pairKey(jobs[i].relPath)}
+		if base, found := strings.CutPrefix(path.Base(keys[0]), "img_o"); found {
+			keys = append(keys, path.Join(path.Dir(keys[0]), "img_"+base))
+		}
+		for _, key := range keys {
+			oi, ok := appleAsset(jobs, full, key)
+			if ok && oi != i && !dropped[oi] {
+				aae := jobs[i]
+				jobs[oi].companions = append(jobs[oi].companions, &aae)
+				dropped[i] = true
+				break
+			}
+		}
+	}
+	return dropJobs(jobs, dropped)
+}
+
+// appleAsset finds the media file a sidecar stem refers to, trying the
+// extensions Photos actually exports.
+func appleAsset(jobs []job, full map[string]int, key string) (int, bool) {
+	for _, ext := range []string{".heic", ".heif", ".jpg", ".jpeg", ".png", ".mov", ".mp4"} {
+		if i, ok := full[key+ext]; ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Archive sources: a source argument may be a .zip, .tar or .tar.gz file
+// (e.g. a Google Takeout download). Entries flow through the same
+// sort/copy pipeline as regular files; each entry is spooled to a single
+// temp file while its worker processes it, so the archive is never fully
+// extracted to disk.
+
+func isArchiveSource(p string) bool {
+	info, err := os.Stat(p)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	lower := strings.ToLower(p)
+	return strings.HasSuffix(lower, ".zip") ||
+		strings.HasSuffix(lower, ".tar") ||
+		strings.HasSuffix(lower, ".tar.gz") ||
+		strings.HasSuffix(lower, ".tgz")
+}
+
+// scanArchive lists an archive's regular-file entries as jobs. Only
+// headers are read here; entry contents are pulled later, per worker.
+func scanArchive(p string) ([]job, error) {
+	var jobs []job
+	add := func(name string, size int64, mtime time.Time) {
+		name = path.Clean(strings.TrimPrefix(name, "/"))
+		if name == "." || strings.HasPrefix(name, "../") {
+			return // don't let hostile archives escape the tree
+		}
+		for _, el := range strings.Split(name, "/") {
+			if excludedDir(el) {
+				debugf("skip %s!%s (-exclude-dir)\n", p, name)
+				recordSkip(p+"!"+name, "excluded dir")
+				return
+			}
+			if skipName(el) {
+				debugf("skip %s!%s (hidden/system)\n", p, name)
+				recordSkip(p+"!"+name, "hidden/system")
+				return
+			}
+		}
+		if sizeFiltered(size) {
+			debugf("skip %s!%s (size filter)\n", p, name)
+			recordSkip(p+"!"+name, "size filter")
+			return
+		}
+		jobs = append(jobs, job{
+			srcPath:   p + "!" + name,
+			srcRoot:   p,
+			relPath:   name,
+			size:      size,
+			mtime:     mtime,
+			entryName: name,
+		})
+	}
+	if strings.HasSuffix(strings.ToLower(p), ".zip") {
+		r, err := zip.OpenReader(p)
+		if err != nil {
+			return nil, fmt.Errorf("open archive %s: %w", p, err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			if f.FileInfo().Mode().IsRegular() {
+				add(f.Name, int64(f.UncompressedSize64), f.Modified)
+			}
+		}
+		return jobs, nil
+	}
+	tr, closeAll, err := openTar(p)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive %s: %w", p, err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			add(hdr.Name, hdr.Size, hdr.ModTime)
+		}
+	}
+	return jobs, nil
+}
+
+// spoolEntry writes one archive entry to a temp file and returns its
+// path. The caller removes it when done.
+func spoolEntry(j *job) (string, error) {
+	rc, err := openEntry(j.srcRoot, j.entryName)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	tmp, err := os.CreateTemp("", "copysort-entry-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if !j.mtime.IsZero() {
+		os.Chtimes(tmp.Name(), j.mtime, j.mtime)
+	}
+	return tmp.Name(), nil
+}
+
+// openEntry returns a reader for one entry. Zip gives random access;
+// tar requires scanning from the front, which makes huge .tar.gz sources
+// slower than their .zip equivalents.
+func openEntry(archive, name string) (io.ReadCloser, error) {
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		r, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range r.File {
+			if path.Clean(strings.TrimPrefix(f.Name, "/")) == name {
+				rc, err := f.Open()
+				if err != nil {
+					r.Close()
+					return nil, err
+				}
+				return &entryReader{rc, r.Close}, nil
+			}
+		}
+		r.Close()
+		return nil, fmt.Errorf("%s: entry %s not found", archive, name)
+	}
+	tr, closeAll, err := openTar(archive)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("%s: entry %s not found", archive, name)
+		}
+		if path.Clean(strings.TrimPrefix(hdr.Name, "/")) == name {
+			return &entryReader{io.NopCloser(tr), closeAll}, nil
+		}
+	}
+}
+
+func openTar(p string) (*tar.Reader, func() error, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open archive %s: %w", p, err)
+	}
+	var r io.Reader = f
+	closeAll := f.Close
+	lower := strings.ToLower(p)
+	if strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("open archive %s: %w", p, err)
+		}
+		r = gz
+		closeAll = func() error {
+			gz.Close()
+			return f.Close()
+		}
+	}
+	return tar.NewReader(r), closeAll, nil
+}
+
+type entryReader struct {
+	io.ReadCloser
+	closeArchive func() error
+}
+
+func (e *entryReader) Close() error {
+	err := e.ReadCloser.Close()
+	if cerr := e.closeArchive(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"time"
+)
+
+// Adaptive worker count: -auto-workers starts the run narrow at
+// -min-workers and hill-climbs toward -workers, sampling aggregate
+// throughput every few seconds. A step that improved throughput keeps
+// its direction, one that hurt it reverses, and fresh errors step down
+// immediately. Many small files on an SSD settle wide; large sequential
+// video on a spinning disk settles narrow — without anyone tuning
+// -workers by hand.
+
+const autotuneInterval = 10 * time.Second
+
+func startAutotune(st *stats) {
+	if !opts.autoWorkers {
+		return
+	}
+	go func() {
+		limit, dir := opts.minWorkers, 1
+		var lastBytes, lastErrs int64
+		var lastRate float64
+		lastTick := time.Now()
+		for range time.Tick(autotuneInterval) {
+			now := time.Now()
+			bytes, errs := st.bytes.Load(), st.errors.Load()
+			rate := float64(bytes-lastBytes) / now.Sub(lastTick).Seconds()
+			switch {
+			case errs > lastErrs:
+				dir = -1
+			case rate < lastRate*0.9:
+				dir = -dir // the last step hurt, walk it back
+			}
+			next := limit + dir
+			if next < opts.minWorkers {
+				next = opts.minWorkers
+			}
+			if next > opts.workers {
+				next = opts.workers
+			}
+			if next != limit {
+				limit = next
+				setWorkerLimit(limit)
+				debugf("autotune: %d workers (%s/s)\n", limit, humanBytes(int64(rate)))
+			}
+			lastBytes, lastErrs, lastRate, lastTick = bytes, errs, rate, now
+		}
+	}()
+}
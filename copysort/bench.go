@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Profiling and benchmarking: -pprof serves the standard pprof handlers
+// so a slow import can be profiled live, and -bench answers "what would
+// I have to upgrade" without writing a byte — it times the walk, a
+// metadata-extraction pass, and a full read of every source file
+// separately, so EXIF decoding, the source disk, and raw throughput each
+// get their own number.
+
+func startPprof(addr string) {
+	go func() {
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			warnf("pprof: %v\n", err)
+		}
+	}()
+}
+
+// runBench re-reads the scanned sources with the normal worker count and
+// reports per-phase throughput instead of copying.
+func runBench(jobs []job, walk time.Duration) {
+	fmt.Printf("walk:  %d files in %v (%.0f files/s)\n",
+		len(jobs), walk.Round(time.Millisecond), float64(len(jobs))/walk.Seconds())
+
+	var media []*job
+	for i := range jobs {
+		if isMedia(jobs[i].relPath) && jobs[i].entryName == "" {
+			media = append(media, &jobs[i])
+		}
+	}
+	start := time.Now()
+	benchPool(media, func(j *job) {
+		extractTimestamp(j.srcPath, path.Base(j.relPath))
+	})
+	d := time.Since(start)
+	fmt.Printf("exif:  %d media files in %v (%.0f files/s)\n",
+		len(media), d.Round(time.Millisecond), float64(len(media))/d.Seconds())
+
+	var all []*job
+	var bytes atomic.Int64
+	for i := range jobs {
+		if jobs[i].entryName == "" && jobs[i].symlink == "" {
+			all = append(all, &jobs[i])
+		}
+	}
+	start = time.Now()
+	benchPool(all, func(j *job) {
+		f, err := os.Open(j.srcPath)
+		if err != nil {
+			return
+		}
+		n, _ := io.Copy(io.Discard, f)
+		f.Close()
+		bytes.Add(n)
+	})
+	d = time.Since(start)
+	fmt.Printf("read:  %s in %v (%s/s, %d workers)\n",
+		humanBytes(bytes.Load()), d.Round(time.Millisecond),
+		humanBytes(int64(float64(bytes.Load())/d.Seconds())), opts.workers)
+}
+
+func benchPool(jobs []*job, fn func(*job)) {
+	ch := make(chan *job)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				fn(j)
+			}
+		}()
+	}
+	for _, j := range jobs {
+		ch <- j
+	}
+	close(ch)
+	wg.Wait()
+}
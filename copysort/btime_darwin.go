@@ -0,0 +1,40 @@
+//go:build darwin
+
+package main
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// setBirthTime stamps the file's creation time via setattrlist so
+// Finder's date-created sorting matches the folder structure.
+func setBirthTime(path string, t time.Time) error {
+	type attrList struct {
+		bitmapCount uint16
+		reserved    uint16
+		commonAttr  uint32
+		volAttr     uint32
+		dirAttr     uint32
+		fileAttr    uint32
+		forkAttr    uint32
+	}
+	const (
+		attrBitMapCount = 5
+		attrCmnCrtime   = 0x00000200
+	)
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	al := attrList{bitmapCount: attrBitMapCount, commonAttr: attrCmnCrtime}
+	ts := syscall.Timespec{Sec: t.Unix(), Nsec: int64(t.Nanosecond())}
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETATTRLIST,
+		uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&al)),
+		uintptr(unsafe.Pointer(&ts)), unsafe.Sizeof(ts), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
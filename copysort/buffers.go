@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Copy buffers: every streaming copy draws from one pool of reusable
+// buffers instead of letting io.Copy allocate 32 KiB a time. -copy-buffer
+// sizes them (bigger buffers help large sequential video copies). The
+// buffers are page-aligned so the same pool serves -odirect writes.
+
+const bufAlign = 4096
+
+var bufPool struct {
+	size int
+	pool sync.Pool
+}
+
+func initBuffers(spec string) error {
+	n, err := parseSize(spec)
+	if err != nil {
+		return fmt.Errorf("-copy-buffer: %w", err)
+	}
+	// O_DIRECT needs block-multiple writes; round up so the only
+	// unaligned write is the final partial block.
+	n = (n + bufAlign - 1) &^ (bufAlign - 1)
+	bufPool.size = n
+	bufPool.pool.New = func() any { return alignedBuf(n) }
+	return nil
+}
+
+func getBuf() []byte  { return bufPool.pool.Get().([]byte) }
+func putBuf(b []byte) { bufPool.pool.Put(b) }
+
+// alignedBuf allocates a page-aligned buffer by over-allocating and
+// slicing to the first aligned byte.
+func alignedBuf(size int) []byte {
+	raw := make([]byte, size+bufAlign)
+	off := int(bufAlign - uintptr(unsafe.Pointer(&raw[0]))&(bufAlign-1))
+	if off == bufAlign {
+		off = 0
+	}
+	return raw[off : off+size : off+size]
+}
+
+// parseSize parses "512K", "4M", "1G" (decimal bytes without a suffix).
+func parseSize(s string) (int, error) {
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "IB")
+	s = strings.TrimSuffix(s, "B")
+	mult := 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1<<10, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1<<20, s[:len(s)-1]
+	case strings.HasSuffix(s, "G"):
+		mult, s = 1<<30, s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
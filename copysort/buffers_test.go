@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"512K", 512 << 10, true},
+		{"4M", 4 << 20, true},
+		{"1G", 1 << 30, true},
+		{"1MiB", 1 << 20, true},
+		{"64kb", 64 << 10, true},
+		{" 2M ", 2 << 20, true},
+		{"100", 100, true},
+		{"", 0, false},
+		{"fast", 0, false},
+		{"0", 0, false},
+		{"-1M", 0, false},
+	}
+	for _, tc := range tests {
+		got, err := parseSize(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d, ok=%v", tc.in, got, err, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Screenshot and messenger-media routing: screenshots and WhatsApp
+// re-compressions aren't camera shots, and mixing them into the date
+// tree buries the real photos. Three signals classify them — filename
+// patterns, source folder names, and (for PNGs) the absence of embedded
+// metadata — and matches land under screenshots/ or whatsapp/ with the
+// same date layout. -classify=false turns the routing off.
+
+var (
+	// WhatsApp media names: IMG-20190514-WA0001.jpg, VID-...-WA....
+	whatsappRe = regexp.MustCompile(`^(?:IMG|VID|AUD|PTT)-\d{8}-WA\d{4}`)
+
+	// Screenshot names across platforms: Screenshot_20190514-120000.png,
+	// "Screen Shot 2019-05-14 at ...", Bildschirmfoto, Captura.
+	screenshotRe = regexp.MustCompile(`(?i)^(?:screenshot|screen shot|captura|bildschirmfoto)[-_ .]`)
+)
+
+// classifySpecial returns "whatsapp", "screenshots", or "" for ordinary
+// camera media. hasMeta is whether the capture date came from embedded
+// metadata rather than a guess.
+func classifySpecial(rel string, hasMeta bool) string {
+	for _, dir := range strings.Split(path.Dir(rel), "/") {
+		ld := strings.ToLower(dir)
+		if strings.HasPrefix(ld, "whatsapp") {
+			return "whatsapp"
+		}
+		if ld == "screenshots" {
+			return "screenshots"
+		}
+	}
+	base := path.Base(rel)
+	if whatsappRe.MatchString(base) {
+		return "whatsapp"
+	}
+	if screenshotRe.MatchString(base) {
+		return "screenshots"
+	}
+	// A PNG with no embedded metadata is almost always a screen grab;
+	// cameras don't write PNG.
+	if strings.ToLower(path.Ext(base)) == ".png" && !hasMeta {
+		return "screenshots"
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Generic-tree compression: -compress gzip|zstd shrinks files that go
+// through the unsorted relative-path branch (documents, logs, ...) with a
+// .gz/.zst suffix. Media files are already compressed and are left
+// alone. `copysort extract FILE...` reverses it.
+
+var compression struct {
+	suffix string // ".gz" or ".zst", empty when disabled
+}
+
+func initCompress(spec string) error {
+	switch spec {
+	case "":
+		return nil
+	case "gzip":
+		compression.suffix = ".gz"
+	case "zstd":
+		if _, err := exec.LookPath("zstd"); err != nil {
+			return fmt.Errorf("-compress zstd: %w", err)
+		}
+		compression.suffix = ".zst"
+	default:
+		return fmt.Errorf("invalid -compress %q (want gzip or zstd)", spec)
+	}
+	return nil
+}
+
+// compressReader wraps src so reads return compressed bytes. The wait
+// func must be called after the copy to collect any compressor error.
+func compressReader(src io.Reader) (io.Reader, func() error, error) {
+	if compression.suffix == ".zst" {
+		return cmdReader([]string{"zstd", "-q", "-c"}, src)
+	}
+	pr, pw := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, src)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+		errc <- err
+	}()
+	return pr, func() error { return <-errc }, nil
+}
+
+// cmdReader runs argv with src on stdin and returns its stdout as a
+// reader plus a wait func collecting the exit status.
+func cmdReader(argv []string, src io.Reader) (io.Reader, func() error, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = src
+	var errb strings.Builder
+	cmd.Stderr = &errb
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	wait := func() error {
+		if err := cmd.Wait(); err != nil {
+			if msg := strings.TrimSpace(errb.String()); msg != "" {
+				return fmt.Errorf("%s: %s", argv[0], msg)
+			}
+			return fmt.Errorf("%s: %w", argv[0], err)
+		}
+		return nil
+	}
+	return out, wait, nil
+}
+
+// runExtract implements `copysort extract FILE...`: each .gz/.zst file is
+// decompressed next to itself without the suffix, keeping the original.
+func runExtract(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: copysort extract FILE...")
+		os.Exit(2)
+	}
+	status := 0
+	for _, p := range args {
+		if err := extractOne(p); err != nil {
+			fmt.Fprintf(os.Stderr, "copysort: %s: %v\n", p, err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func extractOne(p string) error {
+	var out string
+	switch {
+	case strings.HasSuffix(p, ".gz"):
+		out = strings.TrimSuffix(p, ".gz")
+	case strings.HasSuffix(p, ".zst"):
+		out = strings.TrimSuffix(p, ".zst")
+	default:
+		return fmt.Errorf("not a .gz or .zst file")
+	}
+	in, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var r io.Reader
+	var wait func() error
+	if strings.HasSuffix(p, ".gz") {
+		zr, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r, wait, err = cmdReader([]string{"zstd", "-q", "-d", "-c"}, in)
+		if err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(out), ".copysort-tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if wait != nil {
+		if err := wait(); err != nil {
+			return err
+		}
+	}
+	if info, err := os.Stat(p); err == nil {
+		os.Chtimes(tmp.Name(), time.Now(), info.ModTime())
+	}
+	return os.Rename(tmp.Name(), out)
+}
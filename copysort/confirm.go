@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// speedFileName holds the average throughput of the last completed run
+// against this destination, used to estimate how long the next one will
+// take.
+const speedFileName = ".copysort-speed"
+
+// confirmRun prints a pre-copy summary ("12,431 files, 184.2 GiB,
+// estimated 42 min at recent average speed") and asks for confirmation,
+// so a fat-fingered source argument doesn't launch a massive accidental
+// job. -yes and -dry-run skip the prompt.
+func confirmRun(jobs []job, destRoot string) bool {
+	var total int64
+	count := 0
+	for i := range jobs {
+		count++
+		total += jobs[i].size
+		for _, c := range jobs[i].companions {
+			count++
+			total += c.size
+		}
+	}
+	summary := fmt.Sprintf("%s files, %s", groupDigits(count), humanBytes(total))
+	if rate := loadSpeed(destRoot); rate > 0 {
+		eta := time.Duration(float64(total)/rate) * time.Second
+		summary += fmt.Sprintf(", estimated %s at recent average speed", eta.Round(time.Minute))
+	}
+	infof("copysort: %s\n", summary)
+
+	if opts.yes || opts.dryRun {
+		return true
+	}
+	fmt.Print("proceed? [y/N] ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+func loadSpeed(destRoot string) float64 {
+	b, err := os.ReadFile(filepath.Join(destRoot, speedFileName))
+	if err != nil {
+		return 0
+	}
+	rate, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// saveSpeed records this run's bytes/sec for the next run's estimate.
+// Runs that copied nothing are not representative and leave the old
+// figure in place.
+func saveSpeed(destRoot string, bytes int64, elapsed time.Duration) {
+	if bytes == 0 || elapsed < time.Second || opts.dryRun {
+		return
+	}
+	rate := float64(bytes) / elapsed.Seconds()
+	os.WriteFile(filepath.Join(destRoot, speedFileName), []byte(fmt.Sprintf("%.0f\n", rate)), 0o644)
+}
+
+// groupDigits formats an integer with thousands separators.
+func groupDigits(n int) string {
+	s := strconv.Itoa(n)
+	if len(s) <= 3 {
+		return s
+	}
+	var out []byte
+	for i, c := range []byte(s) {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			out = append(out, ',')
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
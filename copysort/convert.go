@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// HEIC-to-JPEG conversion: -convert heic=jpeg transcodes HEIC photos to
+// high-quality JPEG during the copy for destinations consumed by
+// software that can't read HEIC. The first of heif-convert, sips, or
+// ImageMagick found on PATH does the work (all three carry EXIF over).
+// -keep-originals archives the untouched HEIC under originals/ in a
+// parallel date tree.
+
+var converter struct {
+	enabled bool
+	tool    string
+}
+
+func initConvert(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	if spec != "heic=jpeg" {
+		return fmt.Errorf("invalid -convert %q (only heic=jpeg is supported)", spec)
+	}
+	if opts.encrypt != "" || opts.compress != "" {
+		return fmt.Errorf("-convert cannot be combined with -encrypt or -compress")
+	}
+	for _, tool := range []string{"heif-convert", "sips", "magick", "convert"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			converter.enabled = true
+			converter.tool = tool
+			return nil
+		}
+	}
+	return fmt.Errorf("-convert heic=jpeg: no converter found (want heif-convert, sips, or ImageMagick)")
+}
+
+func isHeic(ext string) bool { return ext == ".heic" || ext == ".heif" }
+
+// convertHeic transcodes src to a JPEG at dest, via a temp name so a
+// killed run never leaves a half-written JPEG at the final path.
+func convertHeic(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(dest), ".copysort-conv-"+filepath.Base(dest))
+	defer os.Remove(tmp)
+	var cmd *exec.Cmd
+	switch converter.tool {
+	case "heif-convert":
+		cmd = exec.Command("heif-convert", "-q", "92", src, tmp)
+	case "sips":
+		cmd = exec.Command("sips", "-s", "format", "jpeg", "-s", "formatOptions", "92", src, "--out", tmp)
+	default: // magick / convert
+		cmd = exec.Command(converter.tool, src, "-quality", "92", "jpeg:"+tmp)
+	}
+	var errb strings.Builder
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(errb.String()); msg != "" {
+			return fmt.Errorf("%s: %s", converter.tool, msg)
+		}
+		return fmt.Errorf("%s: %w", converter.tool, err)
+	}
+	if info, err := os.Stat(src); err == nil {
+		os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	}
+	return placeFile(tmp, dest)
+}
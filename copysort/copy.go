@@ -0,0 +1,915 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type stats struct {
+	copied  atomic.Int64
+	skipped atomic.Int64
+	errors  atomic.Int64
+	bytes   atomic.Int64
+
+	mu           sync.Mutex
+	byCategory   map[string]*tally // photos / videos / other
+	byMonth      map[string]*tally // destination "YYYY/MM"
+	byDateSource map[string]int    // exif / video / filename / mtime
+	byWorker     []busyTally       // indexed by copy-worker id
+	bySource     map[string]*busyTally
+	dupes        map[string]*tally // source root -> duplicates not re-stored
+	fallbacks    []fallback        // collected when -report-fallbacks is set
+}
+
+// dupeSkip accounts one incoming file that was recognized as a copy of
+// content already in the archive, so the summary can say how much
+// destination space dedupe saved and which source folder held the
+// duplicates.
+func (st *stats) dupeSkip(srcRoot string, size int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.dupes == nil {
+		st.dupes = map[string]*tally{}
+	}
+	t := st.dupes[srcRoot]
+	if t == nil {
+		t = &tally{}
+		st.dupes[srcRoot] = t
+	}
+	t.files++
+	t.bytes += size
+}
+
+// fail counts one failed file; under -strict it aborts the whole run
+// instead, so backup scripts see the problem immediately.
+func (st *stats) fail() {
+	st.errors.Add(1)
+	if opts.strict {
+		fmt.Fprintln(os.Stderr, "copysort: aborting on first error (-strict)")
+		runAtExit()
+		os.Exit(1)
+	}
+}
+
+// fallback is one media file whose date had to be guessed.
+type fallback struct {
+	srcPath string
+	date    time.Time
+	method  string
+}
+
+type tally struct {
+	files int
+	bytes int64
+}
+
+// busyTally adds the wall time a worker (or a source root's files)
+// spent in processJob, so the summary can show throughput and point at
+// the slow card or hub port in a multi-source import.
+type busyTally struct {
+	tally
+	busy time.Duration
+}
+
+// workTime accounts one processed job to its copy worker and source root.
+func (st *stats) workTime(id int, srcRoot string, size int64, busy time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for len(st.byWorker) <= id {
+		st.byWorker = append(st.byWorker, busyTally{})
+	}
+	w := &st.byWorker[id]
+	w.files++
+	w.bytes += size
+	w.busy += busy
+	if st.bySource == nil {
+		st.bySource = map[string]*busyTally{}
+	}
+	s := st.bySource[srcRoot]
+	if s == nil {
+		s = &busyTally{}
+		st.bySource[srcRoot] = s
+	}
+	s.files++
+	s.bytes += size
+	s.busy += busy
+}
+
+// record accumulates the per-category / per-month breakdown for one
+// copied file. month and dateSource are empty for files that don't
+// date-sort (or companions, which reuse their primary's date).
+func (st *stats) record(category, month, dateSource string, size int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.byCategory == nil {
+		st.byCategory = map[string]*tally{}
+		st.byMonth = map[string]*tally{}
+		st.byDateSource = map[string]int{}
+	}
+	bump := func(m map[string]*tally, k string) {
+		t := m[k]
+		if t == nil {
+			t = &tally{}
+			m[k] = t
+		}
+		t.files++
+		t.bytes += size
+	}
+	bump(st.byCategory, category)
+	if month != "" {
+		bump(st.byMonth, month)
+	}
+	if dateSource != "" {
+		st.byDateSource[dateSource]++
+	}
+}
+
+func category(rel string) string {
+	ext := strings.ToLower(filepath.Ext(rel))
+	switch {
+	case photoExts[ext]:
+		return "photos"
+	case videoExts[ext]:
+		return "videos"
+	default:
+		return "other"
+	}
+}
+
+// destMu guards collision resolution: checking a destination path and
+// claiming it must be atomic across workers.
+var (
+	destMu  sync.Mutex
+	claimed = map[string]bool{}
+)
+
+// processJob computes the destination for one source file and copies it,
+// unless the manifest says it has already been imported.
+func processJob(j *job, destRoot string, m *manifest, st *stats) {
+	destRoot = routeDest(j.relPath, destRoot)
+	if m.has(m.key(j)) {
+		if m.fresh(j) {
+			st.skipped.Add(1)
+			debugf("skip %s (in manifest)\n", j.srcPath)
+			recordSkip(j.srcPath, "in manifest")
+			// Under hash keys an in-manifest hit on a fresh source is
+			// content-level dedupe, not just a re-run of the same path.
+			if opts.manifestKey == "hash" && j.mkey != "" {
+				st.dupeSkip(j.srcRoot, j.size)
+			}
+			return
+		}
+		// The source was edited or re-exported since it was archived;
+		// the new content gets a versioned name from claimDest rather
+		// than a silent skip.
+		debugf("source changed since archived, re-copying %s\n", j.srcPath)
+	}
+
+	if j.symlink != "" {
+		copyLink(j, destRoot, m, st)
+		return
+	}
+
+	readPath := j.srcPath
+	if j.entryName != "" {
+		spool, err := spoolEntry(j)
+		if err != nil {
+			if retryLater(j, err) || lastChanceRetry(j, err) {
+				return
+			}
+			errorf("%s: %v\n", j.srcPath, err)
+			recordSkip(j.srcPath, "error: "+err.Error())
+			st.fail()
+			return
+		}
+		defer os.Remove(spool)
+		readPath = spool
+	}
+
+	if opts.minRating > 0 || opts.keyword != "" {
+		if keep, why := curationFilter(readPath, j.srcPath, j.relPath); !keep {
+			st.skipped.Add(1)
+			debugf("skip %s (%s)\n", j.srcPath, why)
+			recordSkip(j.srcPath, why)
+			return
+		}
+	}
+
+	var destRel, month, dateSource, dkey, model string
+	var when time.Time
+	compressThis := false
+	convertThis := false
+	if isMedia(j.relPath) {
+		var t time.Time
+		var src, mdl string
+		if j.meta != nil {
+			t, src, mdl = j.meta.when, j.meta.source, j.meta.model
+		} else {
+			t, src, mdl = extractTimestamp(readPath, path.Base(j.relPath))
+		}
+		when, model = t, mdl
+		name := normalizeName(path.Base(j.relPath))
+		if opts.rename != "" {
+			name = expandRename(opts.rename, t, model, name)
+		}
+		if converter.enabled && isHeic(strings.ToLower(path.Ext(j.relPath))) {
+			convertThis = true
+			name = strings.TrimSuffix(name, path.Ext(name)) + ".jpg"
+		}
+		destRel = path.Join(expandTemplate(opts.template, t), j.subdir, name)
+		if opts.classify {
+			hasMeta := src == dateFromExif || src == dateFromVideo
+			if kind := classifySpecial(j.relPath, hasMeta); kind != "" {
+				destRel = path.Join(kind, destRel)
+			}
+		}
+		month = fmt.Sprintf("%04d/%02d", t.Year(), int(t.Month()))
+		dateSource = src
+		if opts.reportFallbacks && (src == dateFromFilename || src == dateFromModTime) {
+			st.mu.Lock()
+			st.fallbacks = append(st.fallbacks, fallback{j.srcPath, t, src})
+			st.mu.Unlock()
+		}
+		if opts.dupePolicy == "keep-largest" && src == dateFromExif && model != "" {
+			dkey = dupeKey(t, model)
+			if dupeCheck(dkey, destRoot, j.size, m, st) {
+				destRel = path.Join(quarantineDirName, normalizeName(path.Base(j.relPath)))
+				dkey = ""
+			}
+		}
+	} else {
+		destRel = path.Join("unsorted", normalizeName(j.relPath))
+		if compression.suffix != "" {
+			compressThis = true
+			destRel += compression.suffix
+		}
+	}
+	if dateSource != "" {
+		debugf("dest %s -> %s (dated via %s)\n", j.srcPath, destRel, dateSource)
+	} else {
+		debugf("dest %s -> %s\n", j.srcPath, destRel)
+	}
+	if hook.script != "" {
+		rel, skip, err := hookRoute(j, destRel, dateSource, model, when)
+		if err != nil {
+			if lastChanceRetry(j, err) {
+				return
+			}
+			errorf("%s: %v\n", j.srcPath, err)
+			recordSkip(j.srcPath, "error: "+err.Error())
+			st.fail()
+			return
+		}
+		if skip {
+			st.skipped.Add(1)
+			debugf("skip %s (hook)\n", j.srcPath)
+			recordSkip(j.srcPath, "hook")
+			return
+		}
+		destRel = rel
+	}
+	if tarDest.mode != "" {
+		tarCopy(j, readPath, destRel, dateSource, when, month, m, st)
+		return
+	}
+	destRel += encrypt.suffix
+
+	if len(destPool.roots) > 1 && destRoot == destPool.roots[0] {
+		year := 0
+		if dateSource != "" {
+			year = when.Year()
+		}
+		destRoot = poolPick(year)
+	}
+	destPath, err := claimDest(destRoot, destRel, stableSeq(when, j.relPath), j.size, j.mtime)
+	if err != nil {
+		if lastChanceRetry(j, err) {
+			return
+		}
+		errorf("%s: %v\n", j.srcPath, err)
+		recordSkip(j.srcPath, "error: "+err.Error())
+		st.fail()
+		return
+	}
+	if destPath == "" {
+		st.skipped.Add(1)
+		debugf("skip %s (already at destination)\n", j.srcPath)
+		recordSkip(j.srcPath, "already at destination")
+		st.dupeSkip(j.srcRoot, j.size)
+		return
+	}
+
+	if opts.dryRun {
+		debugf("would copy %s -> %s\n", j.srcPath, destPath)
+		st.copied.Add(1)
+		return
+	}
+
+	rel, _ := filepath.Rel(destRoot, destPath)
+	destRec := poolRecord(destRoot, destPath, rel)
+	if err := m.pending(m.key(j), destRec); err != nil {
+		errorf("manifest: %v\n", err)
+		st.fail()
+		return
+	}
+	var sum string
+	if convertThis {
+		if err := convertHeic(readPath, destPath); err != nil {
+			if destLost(destRoot, err) {
+				awaitDest(destRoot)
+				unclaim(destPath)
+				requeue(j)
+				return
+			}
+			if spillDest(destRoot, err) {
+				unclaim(destPath)
+				requeue(j)
+				return
+			}
+			if lastChanceRetry(j, err) {
+				unclaim(destPath)
+				return
+			}
+			errorf("%s: %v\n", j.srcPath, err)
+			recordSkip(j.srcPath, "error: "+err.Error())
+			st.fail()
+			return
+		}
+		if opts.keepOriginals {
+			orig := filepath.Join(destRoot, "originals", strings.TrimSuffix(rel, ".jpg")+path.Ext(j.relPath))
+			if _, err := copyFile(readPath, orig, false); err != nil {
+				errorf("%s: keep original: %v\n", j.srcPath, err)
+				st.fail()
+			}
+		}
+	} else {
+		c0 := time.Now()
+		s, err := copyFile(readPath, destPath, compressThis)
+		if err != nil {
+			if destLost(destRoot, err) {
+				awaitDest(destRoot)
+				unclaim(destPath)
+				requeue(j)
+				return
+			}
+			if spillDest(destRoot, err) {
+				unclaim(destPath)
+				requeue(j)
+				return
+			}
+			if retryLater(j, err) || lastChanceRetry(j, err) {
+				unclaim(destPath)
+				return
+			}
+			errorf("%s: %v\n", j.srcPath, err)
+			recordSkip(j.srcPath, "error: "+err.Error())
+			st.fail()
+			return
+		}
+		sum = s
+		if j.entryName == "" {
+			recordReadRate(j, time.Since(c0))
+		}
+	}
+	// The manifest's sha256 column must describe the *source* so
+	// -verify-skips can compare against it later; sum stops matching
+	// the source once the copy path transforms the bytes.
+	srcSum := sum
+	if convertThis || compressThis || encrypt.suffix != "" {
+		srcSum = ""
+	}
+	if opts.stripGPS && stripGPSExt(destPath) {
+		changed, err := stripGPS(destPath)
+		if err != nil {
+			warnf("%s: strip gps: %v\n", j.srcPath, err)
+		} else if changed {
+			debugf("stripped gps from %s\n", destPath)
+			if sum != "" {
+				if s, err := hashFile(destPath); err == nil {
+					sum = s
+				}
+			}
+		}
+	}
+	if opts.mtime == "capture" && dateSource != "" {
+		if err := os.Chtimes(destPath, when, when); err != nil {
+			debugf("mtime %s: %v\n", destPath, err)
+		}
+	}
+	if opts.birthtime && dateSource != "" {
+		if err := setBirthTime(destPath, when); err != nil {
+			debugf("birthtime %s: %v\n", destPath, err)
+		}
+	}
+	if opts.xattrSums && sum != "" {
+		if err := setXattrSum(destPath, sum); err != nil {
+			warnf("%s: xattr: %v\n", destPath, err)
+		}
+	}
+	if !convertThis && !compressThis && encrypt.suffix == "" && j.entryName == "" &&
+		!(opts.stripGPS && stripGPSExt(destPath)) {
+		recordVerifyPair(j.srcPath, destPath)
+	}
+	st.addSum(sum, destRec)
+	if err := m.append(m.key(j), destRec, j.size, srcSum); err != nil {
+		errorf("manifest: %v\n", err)
+		st.fail()
+		return
+	}
+	if isMedia(j.relPath) {
+		recordExifIndex(destRec, when, model)
+	}
+	if dkey != "" {
+		dupeRecord(dkey, m.key(j), destPath, j.size)
+	}
+	if opts.phash && photoExts[strings.ToLower(filepath.Ext(j.relPath))] {
+		recordPhash(destPath)
+	}
+	if wantThumb(strings.ToLower(filepath.Ext(j.relPath))) {
+		makeThumb(destPath, st)
+	}
+	st.copied.Add(1)
+	st.bytes.Add(j.size)
+	st.record(category(j.relPath), month, dateSource, j.size)
+	recordSession(destPath)
+	debugf("copy %s -> %s\n", j.srcPath, destPath)
+	if hook.script != "" {
+		hookDone(j, destPath)
+	}
+	if albums.mode != "" && isMedia(j.relPath) {
+		recordAlbum(j.relPath, destRoot, filepath.ToSlash(rel), st)
+	}
+	if uploader.base != "" && isMedia(j.relPath) {
+		if err := uploadAsset(j, readPath, when); err != nil {
+			errorf("upload %s: %v\n", j.srcPath, err)
+			st.fail()
+		}
+	}
+	for _, c := range j.companions {
+		copyCompanion(j, c, destRoot, destPath, month, m, st)
+	}
+}
+
+// copyLink recreates a source symlink in the unsorted tree, preserving
+// the original target string rather than copying what it points at.
+func copyLink(j *job, destRoot string, m *manifest, st *stats) {
+	destRel := path.Join("unsorted", normalizeName(j.relPath))
+	destPath, err := claimDest(destRoot, destRel, "", -1, time.Time{})
+	if err != nil {
+		errorf("%s: %v\n", j.srcPath, err)
+		recordSkip(j.srcPath, "error: "+err.Error())
+		st.fail()
+		return
+	}
+	if destPath == "" {
+		st.skipped.Add(1)
+		recordSkip(j.srcPath, "already at destination")
+		return
+	}
+	if opts.dryRun {
+		debugf("would link %s -> %s\n", destPath, j.symlink)
+		st.copied.Add(1)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err == nil {
+		err = os.Symlink(j.symlink, destPath)
+	}
+	if err != nil {
+		errorf("%s: %v\n", j.srcPath, err)
+		recordSkip(j.srcPath, "error: "+err.Error())
+		st.fail()
+		return
+	}
+	rel, _ := filepath.Rel(destRoot, destPath)
+	if err := m.append(m.key(j), filepath.ToSlash(rel), -1, ""); err != nil {
+		errorf("manifest: %v\n", err)
+		st.fail()
+		return
+	}
+	st.copied.Add(1)
+	st.record("other", "", "", 0)
+	debugf("link %s -> %s\n", destPath, j.symlink)
+}
+
+// copyCompanion copies a paired file (Live Photo .mov, RAW+JPEG other
+// half) next to its primary, reusing the primary's final stem (including
+// any collision suffix) so the pair stays together under matching names.
+func copyCompanion(j, c *job, destRoot, primaryDest, month string, m *manifest, st *stats) {
+	if m.has(m.key(c)) && m.fresh(c) {
+		st.skipped.Add(1)
+		recordSkip(c.srcPath, "in manifest")
+		return
+	}
+	readPath := c.srcPath
+	if c.entryName != "" {
+		spool, err := spoolEntry(c)
+		if err != nil {
+			errorf("%s: %v\n", c.srcPath, err)
+			recordSkip(c.srcPath, "error: "+err.Error())
+			st.fail()
+			return
+		}
+		defer os.Remove(spool)
+		readPath = spool
+	}
+	dateDir := filepath.Dir(primaryDest)
+	if j.subdir != "" {
+		dateDir = filepath.Dir(dateDir)
+	}
+	base := strings.TrimSuffix(filepath.Base(primaryDest), encrypt.suffix)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	dest := filepath.Join(dateDir, filepath.FromSlash(c.subdir), stem+filepath.Ext(c.relPath)+encrypt.suffix)
+	destMu.Lock()
+	claimed[dest] = true
+	destMu.Unlock()
+	if opts.dryRun {
+		debugf("would copy %s -> %s\n", c.srcPath, dest)
+		st.copied.Add(1)
+		return
+	}
+	rel, _ := filepath.Rel(destRoot, dest)
+	destRec := poolRecord(destRoot, dest, rel)
+	if err := m.pending(m.key(c), destRec); err != nil {
+		errorf("manifest: %v\n", err)
+		st.fail()
+		return
+	}
+	sum, err := copyFile(readPath, dest, false)
+	if err != nil && destLost(destRoot, err) {
+		// The primary is already on disk, so ride out the disconnect
+		// here and try the companion once more.
+		awaitDest(destRoot)
+		sum, err = copyFile(readPath, dest, false)
+	}
+	if err != nil {
+		errorf("%s: %v\n", c.srcPath, err)
+		recordSkip(c.srcPath, "error: "+err.Error())
+		st.fail()
+		return
+	}
+	if opts.xattrSums && sum != "" {
+		if err := setXattrSum(dest, sum); err != nil {
+			warnf("%s: xattr: %v\n", dest, err)
+		}
+	}
+	if encrypt.suffix == "" && c.entryName == "" {
+		recordVerifyPair(c.srcPath, dest)
+	}
+	st.addSum(sum, destRec)
+	csum := sum
+	if encrypt.suffix != "" {
+		csum = ""
+	}
+	if err := m.append(m.key(c), destRec, c.size, csum); err != nil {
+		errorf("manifest: %v\n", err)
+		st.fail()
+		return
+	}
+	st.copied.Add(1)
+	st.bytes.Add(c.size)
+	st.record(category(c.relPath), month, "", c.size)
+	recordSession(dest)
+	debugf("copy %s -> %s\n", c.srcPath, dest)
+}
+
+// unclaim releases a destination claim so a retried job can resolve to
+// the same path instead of picking up a spurious _2 suffix.
+func unclaim(destPath string) {
+	destMu.Lock()
+	delete(claimed, destPath)
+	destMu.Unlock()
+}
+
+// claimDest resolves collisions on the destination path. A collider
+// first tries a suffix built from seq — a discriminator stable across
+// runs, so repeated imports of the same card produce the same names —
+// and only falls back to appending _2, _3, ... in claim order when it
+// has none or the stable name is taken too. It returns "" if a file of
+// the same size and mtime is already at the destination (treated as
+// already copied); the mtime comparison tolerates FAT's 2-second
+// resolution, so SD-card destinations don't re-copy everything on every
+// pass. Pass size -1 to disable the identical-file check.
+func claimDest(destRoot, destRel, seq string, size int64, mtime time.Time) (string, error) {
+	destMu.Lock()
+	defer destMu.Unlock()
+	base := filepath.Join(destRoot, filepath.FromSlash(destRel))
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	try := func(candidate string) (bool, error) {
+		if claimed[candidate] {
+			return false, nil
+		}
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			claimed[candidate] = true
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	ok, err := try(base)
+	if ok || err != nil {
+		return base, err
+	}
+	if size >= 0 {
+		if fi, err := os.Stat(base); err == nil && fi.Size() == size &&
+			mtimeMatch(base, fi.ModTime().UnixNano(), mtime.UnixNano()) {
+			return "", nil
+		}
+	}
+	if seq != "" {
+		candidate := fmt.Sprintf("%s_%s%s", stem, seq, ext)
+		if ok, err := try(candidate); ok || err != nil {
+			return candidate, err
+		}
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s_%d%s", stem, n, ext)
+		if ok, err := try(candidate); ok || err != nil {
+			return candidate, err
+		}
+	}
+}
+
+// fileNumRe pulls the camera file number out of an original basename
+// (IMG_0042.JPG -> 0042).
+var fileNumRe = regexp.MustCompile(`(\d+)\.[^.]+$`)
+
+// stableSeq derives a run-independent collision discriminator for a
+// file: the EXIF sub-second counter when the capture time carries one,
+// else the camera file number from the original name. Without it the
+// _2/_3 suffixes land in whatever order the workers finish.
+func stableSeq(when time.Time, relPath string) string {
+	if ms := when.Nanosecond() / int(time.Millisecond); ms != 0 {
+		return fmt.Sprintf("%03d", ms)
+	}
+	if m := fileNumRe.FindStringSubmatch(path.Base(relPath)); m != nil {
+		if n := strings.TrimLeft(m[1], "0"); n != "" {
+			return n
+		}
+	}
+	return ""
+}
+
+// granularityTemplates are the -granularity presets: how deep the date
+// tree goes. A folder per day means thousands of tiny folders for casual
+// phone libraries; per-month is often the browsable choice.
+var granularityTemplates = map[string]string{
+	"year":    "{year}",
+	"quarter": "{year}/{quarter}",
+	"month":   "{year}/{month}",
+	"week":    "{week}",
+	"day":     "{year}/{month}/{day}",
+}
+
+const defaultTemplate = "{year}/{month}/{day}"
+
+func applyGranularity() error {
+	if opts.granularity == "" {
+		return nil
+	}
+	tmpl, ok := granularityTemplates[opts.granularity]
+	if !ok {
+		return fmt.Errorf("invalid -granularity %q (want year, quarter, month, week or day)", opts.granularity)
+	}
+	if opts.template != defaultTemplate {
+		return fmt.Errorf("-granularity and -template are mutually exclusive")
+	}
+	opts.template = tmpl
+	return nil
+}
+
+// monthNames holds the names {monthname} renders; -month-names swaps in
+// localized ones ("janvier,février,...").
+var monthNames = [12]string{
+	"January", "February", "March", "April", "May", "June",
+	"July", "August", "September", "October", "November", "December",
+}
+
+func loadMonthNames(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	names := strings.Split(spec, ",")
+	if len(names) != 12 {
+		return fmt.Errorf("-month-names: want 12 comma-separated names, got %d", len(names))
+	}
+	for i, n := range names {
+		monthNames[i] = strings.TrimSpace(n)
+	}
+	return nil
+}
+
+// expandTemplate renders the destination path template for a timestamp.
+// Besides the numeric fields there is {monthname} (named month folders
+// like "07-July" via "{month}-{monthname}") and {date} (YYYY-MM-DD, for
+// flat-day layouts like "{year}/{date}"). {offset} is the UTC offset the
+// capture time was recorded in (from EXIF OffsetTime when present),
+// colon-free so it is filesystem-safe.
+func expandTemplate(tmpl string, t time.Time) string {
+	wyear, week := t.ISOWeek()
+	r := strings.NewReplacer(
+		"{year}", fmt.Sprintf("%04d", t.Year()),
+		"{month}", fmt.Sprintf("%02d", int(t.Month())),
+		"{monthname}", monthNames[int(t.Month())-1],
+		"{day}", fmt.Sprintf("%02d", t.Day()),
+		"{date}", t.Format("2006-01-02"),
+		"{week}", fmt.Sprintf("%04d-W%02d", wyear, week),
+		"{quarter}", fmt.Sprintf("Q%d", (int(t.Month())+2)/3),
+		"{hour}", fmt.Sprintf("%02d", t.Hour()),
+		"{minute}", fmt.Sprintf("%02d", t.Minute()),
+		"{offset}", t.Format("-0700"),
+	)
+	return r.Replace(tmpl)
+}
+
+// expandRename builds a destination filename from the -rename template,
+// e.g. "{datetime}_{camera}_{orig}" -> 20230714_153012_Canon-EOS-R6_IMG_0042.JPG.
+// The original extension always survives; an empty field (no camera
+// model, say) is dropped along with the separator in front of it rather
+// than leaving "__" runs in the name.
+func expandRename(tmpl string, t time.Time, model, name string) string {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	r := strings.NewReplacer(
+		"{datetime}", t.Format("20060102_150405"),
+		"{date}", t.Format("20060102"),
+		"{time}", t.Format("150405"),
+		"{camera}", strings.ReplaceAll(model, " ", "-"),
+		"{orig}", stem,
+	)
+	out := r.Replace(tmpl)
+	for strings.Contains(out, "__") {
+		out = strings.ReplaceAll(out, "__", "_")
+	}
+	out = strings.Trim(out, "_")
+	if out == "" {
+		out = stem
+	}
+	return out + ext
+}
+
+// copyFile copies src to dest via a temp file in the destination
+// directory, so a crash never leaves a half-written file at the final
+// name, and preserves the source mtime. The stream optionally passes
+// through the compression and encryption filters, in that order.
+func copyFile(src, dest string, compress bool) (string, error) {
+	in, err := openSource(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".copysort-tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	var h hash.Hash
+	if !compress && encrypt.suffix == "" && !hashingCopies() && likelySparse(info) {
+		if err := copySparseTo(in, tmp, info.Size()); err != nil {
+			tmp.Close()
+			return "", err
+		}
+	} else if opts.odirect && !compress && encrypt.suffix == "" && info.Size() >= odirectMin {
+		if hashingCopies() {
+			h = sha256.New()
+		}
+		if err := copyDirect(in, tmp, h); err != nil {
+			tmp.Close()
+			return "", err
+		}
+	} else if opts.iouring && !compress && encrypt.suffix == "" && !hashingCopies() && uringSupported() {
+		if err := uringCopy(in, tmp, info.Size()); err != nil {
+			tmp.Close()
+			return "", err
+		}
+	} else {
+		var r io.Reader = in
+		if copyAhead.depth > 0 {
+			sr := newStagedReader(r)
+			defer sr.Close()
+			r = sr
+		}
+		var waits []func() error
+		if compress {
+			nr, wait, err := compressReader(r)
+			if err != nil {
+				tmp.Close()
+				return "", err
+			}
+			r, waits = nr, append(waits, wait)
+		}
+		if encrypt.suffix != "" {
+			nr, wait, err := cmdReader(encrypt.argv, r)
+			if err != nil {
+				tmp.Close()
+				return "", err
+			}
+			r, waits = nr, append(waits, wait)
+		}
+		var out io.Writer = tmp
+		if hashingCopies() {
+			h = sha256.New()
+			out = io.MultiWriter(tmp, h)
+		}
+		buf := getBuf()
+		_, err := io.CopyBuffer(out, r, buf)
+		putBuf(buf)
+		if err != nil {
+			tmp.Close()
+			return "", err
+		}
+		for _, wait := range waits {
+			if err := wait(); err != nil {
+				tmp.Close()
+				return "", err
+			}
+		}
+	}
+	if opts.fsync {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return "", err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return "", err
+	}
+	if err := placeFile(tmp.Name(), dest); err != nil {
+		return "", err
+	}
+	if opts.fsync {
+		if err := syncDir(filepath.Dir(dest)); err != nil {
+			return "", err
+		}
+	}
+	var sum string
+	if h != nil {
+		sum = hex.EncodeToString(h.Sum(nil))
+	}
+	return sum, nil
+}
+
+// placeFile moves a finished temp file to its final name. Under
+// -no-overwrite the final name is claimed first with an exclusive
+// create, so nothing already in the archive can ever be replaced —
+// rename alone silently clobbers whatever is at the destination.
+func placeFile(tmp, dest string) error {
+	if opts.noOverwrite {
+		f, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			if os.IsExist(err) {
+				return fmt.Errorf("%s already exists (-no-overwrite)", dest)
+			}
+			return err
+		}
+		f.Close()
+	}
+	return os.Rename(tmp, dest)
+}
+
+// syncDir fsyncs a directory so the rename that just landed in it is
+// durable. "In the manifest" should mean "actually on disk" — for archival
+// imports onto removable drives that guarantee is worth the slowdown.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
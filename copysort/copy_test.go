@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	when := time.Date(2023, 7, 14, 15, 30, 12, 0, time.UTC)
+	tests := []struct {
+		tmpl, want string
+	}{
+		{"{year}/{month}/{day}", "2023/07/14"},
+		{"{year}/{quarter}", "2023/Q3"},
+		{"{year}/{month}-{monthname}", "2023/07-July"},
+		{"{week}", "2023-W28"},
+		{"{year}/{date}", "2023/2023-07-14"},
+		{"{date}_{hour}{minute}", "2023-07-14_1530"},
+		{"{year}{offset}", "2023+0000"},
+		{"plain", "plain"},
+	}
+	for _, tc := range tests {
+		if got := expandTemplate(tc.tmpl, when); got != tc.want {
+			t.Errorf("expandTemplate(%q) = %q, want %q", tc.tmpl, got, tc.want)
+		}
+	}
+}
+
+func TestExpandRename(t *testing.T) {
+	when := time.Date(2023, 7, 14, 15, 30, 12, 0, time.UTC)
+	tests := []struct {
+		tmpl, model, name, want string
+	}{
+		{"{datetime}_{camera}_{orig}", "Canon EOS R6", "IMG_0042.JPG", "20230714_153012_Canon-EOS-R6_IMG_0042.JPG"},
+		// A missing camera model collapses its separator instead of
+		// leaving "__" in the name.
+		{"{datetime}_{camera}_{orig}", "", "IMG_0042.JPG", "20230714_153012_IMG_0042.JPG"},
+		{"{date}_{time}", "", "clip.mov", "20230714_153012.mov"},
+		// A template that expands entirely empty keeps the original name.
+		{"{camera}", "", "holiday.jpg", "holiday.jpg"},
+	}
+	for _, tc := range tests {
+		if got := expandRename(tc.tmpl, when, tc.model, tc.name); got != tc.want {
+			t.Errorf("expandRename(%q, %q, %q) = %q, want %q", tc.tmpl, tc.model, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStableSeq(t *testing.T) {
+	milli := time.Date(2023, 7, 14, 15, 30, 12, 42*int(time.Millisecond), time.Local)
+	whole := time.Date(2023, 7, 14, 15, 30, 12, 0, time.Local)
+	tests := []struct {
+		when    time.Time
+		relPath string
+		want    string
+	}{
+		{milli, "IMG_0042.JPG", "042"}, // sub-second counter wins
+		{whole, "IMG_0042.JPG", "42"},
+		{whole, "a/b/DSC01000.ARW", "1000"},
+		{whole, "DSC00000.jpg", ""}, // an all-zero file number is no discriminator
+		{whole, "holiday.jpg", ""},
+	}
+	for _, tc := range tests {
+		if got := stableSeq(tc.when, tc.relPath); got != tc.want {
+			t.Errorf("stableSeq(%v, %q) = %q, want %q", tc.when, tc.relPath, got, tc.want)
+		}
+	}
+}
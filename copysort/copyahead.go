@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Copy-ahead staging: a plain io.Copy serializes each file — read a
+// buffer, write it, read the next — so a slow source (MTP, network
+// share) and a slow destination add their latencies instead of
+// overlapping. -copy-ahead runs the read side on its own goroutine,
+// staging chunks into a bounded in-memory queue of the given total
+// size that the write side drains concurrently. Chunks come from the
+// shared copy-buffer pool, so the queue depth is the staging size
+// divided by -copy-buffer.
+
+var copyAhead struct {
+	depth int // queue depth in pool buffers; 0 = staging off
+}
+
+func initCopyAhead(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	n, err := parseSize(spec)
+	if err != nil {
+		return fmt.Errorf("-copy-ahead: %w", err)
+	}
+	depth := n / bufPool.size
+	if depth < 2 {
+		depth = 2
+	}
+	copyAhead.depth = depth
+	return nil
+}
+
+// stagedChunk is one filled buffer in flight; err (io.EOF included)
+// rides on the last one.
+type stagedChunk struct {
+	buf []byte
+	n   int
+	err error
+}
+
+// stagedReader reads ahead of its consumer through a bounded channel.
+// Close releases the pump goroutine and its buffers if the consumer
+// stops early.
+type stagedReader struct {
+	ch   chan stagedChunk
+	done chan struct{}
+	cur  stagedChunk
+	off  int
+	err  error
+}
+
+func newStagedReader(r io.Reader) *stagedReader {
+	s := &stagedReader{
+		ch:   make(chan stagedChunk, copyAhead.depth),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.ch)
+		for {
+			buf := getBuf()
+			n, err := io.ReadFull(r, buf)
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			if n == 0 {
+				putBuf(buf)
+				buf = nil
+			}
+			select {
+			case s.ch <- stagedChunk{buf, n, err}:
+			case <-s.done:
+				if buf != nil {
+					putBuf(buf)
+				}
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func (s *stagedReader) Read(p []byte) (int, error) {
+	for s.off == s.cur.n {
+		if s.cur.buf != nil {
+			putBuf(s.cur.buf)
+			s.cur.buf = nil
+		}
+		if s.err != nil {
+			return 0, s.err
+		}
+		c, ok := <-s.ch
+		if !ok {
+			return 0, io.EOF
+		}
+		s.cur, s.off = c, 0
+		s.err = c.err
+	}
+	n := copy(p, s.cur.buf[s.off:s.cur.n])
+	s.off += n
+	return n, nil
+}
+
+// Close stops the pump and returns any buffers still queued to the pool.
+func (s *stagedReader) Close() error {
+	close(s.done)
+	if s.cur.buf != nil {
+		putBuf(s.cur.buf)
+		s.cur.buf = nil
+	}
+	for c := range s.ch {
+		if c.buf != nil {
+			putBuf(c.buf)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// Destination pooling: archives outgrow drives. -spill names additional
+// destination roots, tried in order once the primary reports ENOSPC, so
+// a full drive means newer files continue onto the next one instead of
+// a failed run. -spill-policy by-year keeps whole years together: a
+// year stays on the root that already holds its folder, and only new
+// years start on the next root with room. Run artifacts (manifest,
+// SHA256SUMS, albums index) stay in the primary root; files that land
+// elsewhere are recorded in the manifest by absolute path so `find` and
+// startup reconciliation still reach them.
+
+var destPool struct {
+	policy string // fill-first or by-year; "" = pooling off
+	roots  []string
+
+	mu    sync.Mutex
+	full  map[string]bool
+	years map[int]string
+}
+
+func initDestPool(primary string, extras []string, policy string) error {
+	switch policy {
+	case "fill-first", "by-year":
+	default:
+		return fmt.Errorf("invalid -spill-policy %q (want fill-first or by-year)", policy)
+	}
+	if len(extras) == 0 {
+		return nil
+	}
+	destPool.policy = policy
+	destPool.roots = append([]string{primary}, extras...)
+	destPool.full = map[string]bool{}
+	destPool.years = map[int]string{}
+	for _, r := range extras {
+		if err := os.MkdirAll(r, 0o755); err != nil {
+			return fmt.Errorf("-spill: %w", err)
+		}
+	}
+	return nil
+}
+
+// poolPick chooses the root for one file; year is 0 when the file has
+// no date. With every root full it returns the primary and lets the
+// copy fail honestly.
+func poolPick(year int) string {
+	destPool.mu.Lock()
+	defer destPool.mu.Unlock()
+	if destPool.policy == "by-year" && year > 0 {
+		if r, ok := destPool.years[year]; ok && !destPool.full[r] {
+			return r
+		}
+		for _, r := range destPool.roots {
+			if destPool.full[r] {
+				continue
+			}
+			if fi, err := os.Stat(filepath.Join(r, strconv.Itoa(year))); err == nil && fi.IsDir() {
+				destPool.years[year] = r
+				return r
+			}
+		}
+	}
+	for _, r := range destPool.roots {
+		if !destPool.full[r] {
+			if destPool.policy == "by-year" && year > 0 {
+				destPool.years[year] = r
+			}
+			return r
+		}
+	}
+	return destPool.roots[0]
+}
+
+// spillDest reports whether an error is out-of-space on a pooled root
+// with somewhere left to spill to; the caller requeues the job so the
+// retry pass picks the next root.
+func spillDest(root string, err error) bool {
+	if len(destPool.roots) < 2 || !errors.Is(err, syscall.ENOSPC) {
+		return false
+	}
+	destPool.mu.Lock()
+	defer destPool.mu.Unlock()
+	destPool.full[root] = true
+	for _, r := range destPool.roots {
+		if !destPool.full[r] {
+			warnf("destination %s is full, spilling to %s\n", root, r)
+			return true
+		}
+	}
+	return false
+}
+
+// poolRecord is the manifest destination column for a file: relative to
+// the primary root as usual, absolute when it spilled elsewhere.
+func poolRecord(destRoot, destPath, rel string) string {
+	if len(destPool.roots) > 1 && destRoot != destPool.roots[0] {
+		return filepath.ToSlash(destPath)
+	}
+	return filepath.ToSlash(rel)
+}
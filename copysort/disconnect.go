@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Removable-drive disconnect detection: when a destination write fails
+// and the destination root itself has stopped answering, the drive is
+// gone — without this, 8 workers burn through the remaining queue
+// "failing" every file in seconds. The first worker to notice pauses the
+// gate, asks for the drive back, and polls until the root reappears; the
+// files that failed while disconnected go to the retry queue without
+// spending a retry attempt.
+
+var destWait sync.Mutex // serializes the reconnect prompt across workers
+
+// destLost reports whether a write error means the destination root
+// itself is unreachable — or, for a network destination, no longer the
+// mounted share — rather than a problem with one file.
+func destLost(destRoot string, err error) bool {
+	if err == nil {
+		return false
+	}
+	if destStale(destRoot) {
+		return true
+	}
+	_, serr := os.Stat(destRoot)
+	return serr != nil
+}
+
+// destBack reports whether the destination root is reachable again (and,
+// for network destinations, the original filesystem is mounted).
+func destBack(destRoot string) bool {
+	if _, err := os.Stat(destRoot); err != nil {
+		return false
+	}
+	return !destStale(destRoot)
+}
+
+// awaitDest pauses the run and blocks until the destination root is back.
+func awaitDest(destRoot string) {
+	destWait.Lock()
+	defer destWait.Unlock()
+	if destBack(destRoot) {
+		return // another worker already saw it reconnect
+	}
+	gate.setPaused(true)
+	fmt.Fprintf(os.Stderr, "\ncopysort: destination %s is unreachable — reconnect the drive to resume\n", destRoot)
+	for !destBack(destRoot) {
+		time.Sleep(2 * time.Second)
+	}
+	fmt.Fprintln(os.Stderr, "copysort: destination is back, resuming")
+	gate.setPaused(false)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// `copysort doctor DEST` sanity-checks the environment before a long
+// run starts: destination writability and free space, filesystem quirks
+// worth knowing about (FAT's coarse timestamps, network mounts),
+// manifest readability, and whether EXIF parsing works on a sample of
+// the archive. Each finding prints as one line; exit status 1 means at
+// least one warning.
+
+func runDoctor(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: copysort doctor DEST")
+		os.Exit(2)
+	}
+	initLog("") // silence the walker's per-file debug chatter
+	root := args[0]
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		fmt.Fprintf(os.Stderr, "copysort: %s is not a directory\n", root)
+		os.Exit(2)
+	}
+
+	warns := 0
+	warn := func(format string, a ...any) {
+		warns++
+		fmt.Printf("warn: "+format+"\n", a...)
+	}
+	ok := func(format string, a ...any) {
+		fmt.Printf("  ok: "+format+"\n", a...)
+	}
+
+	probe := filepath.Join(root, netProbeName)
+	if err := os.WriteFile(probe, nil, 0o644); err != nil {
+		warn("destination is not writable: %v", err)
+	} else {
+		os.Remove(probe)
+		ok("destination is writable")
+	}
+
+	if name, free, total, err := fsInfo(root); err == nil {
+		ok("filesystem %s, %s free of %s", name, humanBytes(free), humanBytes(total))
+		if fatFS(name) {
+			warn("FAT-family filesystem: timestamps have 2-second resolution and no timezone, so mtime-based comparisons are approximate")
+		}
+		if free < 1<<30 {
+			warn("less than 1 GiB free — a long run will hit the wall quickly")
+		}
+	} else {
+		fmt.Printf("  --: %v\n", err)
+	}
+
+	switch f, err := os.Open(filepath.Join(root, manifestName)); {
+	case err == nil:
+		n := 0
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for sc.Scan() {
+			if line := sc.Text(); line != "" && !strings.HasPrefix(line, "#") {
+				n++
+			}
+		}
+		if serr := sc.Err(); serr != nil {
+			warn("manifest unreadable past entry %d: %v", n, serr)
+		} else {
+			ok("manifest readable, %d entries", n)
+		}
+		f.Close()
+	case errors.Is(err, fs.ErrNotExist):
+		fmt.Printf("  --: no manifest yet (fine for a first import)\n")
+	default:
+		warn("manifest unreadable: %v", err)
+	}
+
+	if b, err := os.ReadFile(filepath.Join(root, archiveMarkerName)); err == nil {
+		ok("archive marker %q (pass -archive-id %s to enforce it)",
+			strings.TrimSpace(string(b)), strings.TrimSpace(string(b)))
+	}
+
+	var jobs []job
+	w := &walker{root: root, jobs: &jobs}
+	if err := w.walk(root); err != nil {
+		warn("walking destination: %v", err)
+	}
+	sampled, parsed := 0, 0
+	var sample string
+	for i := range jobs {
+		j := &jobs[i]
+		if !isMedia(j.relPath) || !photoExts[strings.ToLower(path.Ext(j.relPath))] {
+			continue
+		}
+		sampled++
+		if _, src, _ := extractTimestamp(j.srcPath, path.Base(j.relPath)); src == dateFromExif {
+			parsed++
+			sample = j.relPath
+		}
+		if sampled >= 50 {
+			break
+		}
+	}
+	switch {
+	case sampled == 0:
+		fmt.Printf("  --: no photos in the archive to sample for EXIF\n")
+	case parsed == 0:
+		warn("none of %d sampled photos yielded EXIF dates — sorting would fall back to filenames and mtimes", sampled)
+	default:
+		ok("EXIF parses on %d of %d sampled photos (e.g. %s)", parsed, sampled, sample)
+	}
+
+	if warns > 0 {
+		fmt.Printf("%d warning(s)\n", warns)
+		os.Exit(1)
+	}
+	fmt.Println("all checks passed")
+	os.Exit(0)
+}
+
+// fatFS reports whether a filesystem name from fsInfo is in the FAT
+// family, with its 2-second mtime resolution and timezone-free
+// timestamps.
+func fatFS(name string) bool {
+	return name == "vfat" || name == "msdos" || name == "exfat"
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Quality-based duplicate policy: -dupe-policy keep-largest treats two
+// incoming files with the same capture timestamp and camera model as the
+// same shot (an "original" and an "optimized" export of one library, for
+// instance), keeps the larger one in the date tree, and diverts the
+// smaller into .copysort-quarantine for review instead of archiving both.
+
+const quarantineDirName = ".copysort-quarantine"
+
+var dupes struct {
+	mu   sync.Mutex
+	seen map[string]dupeRec
+}
+
+type dupeRec struct {
+	srcKey   string // manifest key the archived copy was recorded under
+	destPath string
+	size     int64
+}
+
+func dupeKey(t time.Time, model string) string {
+	return t.Format("2006-01-02T15:04:05") + "|" + model
+}
+
+// dupeCheck decides what to do with a file whose capture key was already
+// seen this run. It returns true if this file should be quarantined; if
+// instead the earlier, smaller copy must make way, it is moved into
+// quarantine here and the new file proceeds normally.
+func dupeCheck(key, destRoot string, size int64, m *manifest, st *stats) (quarantine bool) {
+	dupes.mu.Lock()
+	defer dupes.mu.Unlock()
+	if dupes.seen == nil {
+		dupes.seen = map[string]dupeRec{}
+	}
+	prev, ok := dupes.seen[key]
+	if !ok {
+		return false
+	}
+	if size <= prev.size {
+		return true
+	}
+	// The new file is bigger; demote the earlier copy.
+	if !opts.dryRun && prev.destPath != "" {
+		q := filepath.Join(destRoot, quarantineDirName, filepath.Base(prev.destPath))
+		err := os.MkdirAll(filepath.Dir(q), 0o755)
+		if err == nil {
+			err = os.Rename(prev.destPath, q)
+		}
+		if err != nil {
+			errorf("quarantine %s: %v\n", prev.destPath, err)
+			st.fail()
+		} else {
+			infof("quarantine %s (smaller duplicate of same shot)\n", prev.destPath)
+			// The manifest must follow the file: re-point the demoted
+			// entry at its quarantine path, or find and the skip check
+			// would keep referencing the old date-tree location.
+			rel := path.Join(quarantineDirName, filepath.Base(prev.destPath))
+			if err := m.append(prev.srcKey, rel, prev.size, m.recordedSum(prev.srcKey)); err != nil {
+				errorf("manifest: %v\n", err)
+				st.fail()
+			}
+		}
+	}
+	delete(dupes.seen, key)
+	return false
+}
+
+func dupeRecord(key, srcKey, destPath string, size int64) {
+	dupes.mu.Lock()
+	defer dupes.mu.Unlock()
+	if dupes.seen == nil {
+		dupes.seen = map[string]dupeRec{}
+	}
+	dupes.seen[key] = dupeRec{srcKey, destPath, size}
+}
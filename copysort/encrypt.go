@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Destination encryption: with -encrypt each file is piped through age or
+// gpg on its way to the destination, landing as photo.jpg.age (or .gpg).
+// The manifest stays plaintext and doubles as the index mapping source
+// names to encrypted destination paths, so a sorted archive can live on
+// an untrusted cloud-synced drive and still be restored selectively.
+
+var encrypt struct {
+	suffix string   // ".age" or ".gpg", empty when disabled
+	argv   []string // command producing ciphertext on stdout from stdin
+}
+
+// initEncrypt parses -encrypt values like "age:RECIPIENT[,RECIPIENT...]"
+// or "gpg:KEYID" and checks the tool is on PATH.
+func initEncrypt(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	scheme, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return fmt.Errorf("invalid -encrypt %q (want age:RECIPIENT or gpg:KEYID)", spec)
+	}
+	switch scheme {
+	case "age":
+		encrypt.argv = []string{"age"}
+		for _, r := range strings.Split(arg, ",") {
+			encrypt.argv = append(encrypt.argv, "-r", r)
+		}
+		encrypt.suffix = ".age"
+	case "gpg":
+		encrypt.argv = []string{"gpg", "--batch", "--quiet", "--encrypt", "-r", arg}
+		encrypt.suffix = ".gpg"
+	default:
+		return fmt.Errorf("invalid -encrypt scheme %q (want age or gpg)", scheme)
+	}
+	if _, err := exec.LookPath(encrypt.argv[0]); err != nil {
+		return fmt.Errorf("-encrypt %s: %w", scheme, err)
+	}
+	return nil
+}
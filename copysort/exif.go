@@ -0,0 +1,471 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Where a file's capture timestamp came from, in decreasing order of trust.
+const (
+	dateFromExif     = "exif"
+	dateFromVideo    = "video"
+	dateFromFilename = "filename"
+	dateFromModTime  = "mtime"
+)
+
+// fileMeta is extractTimestamp's result in a form the extract pool can
+// hang off a job for the copy workers.
+type fileMeta struct {
+	when   time.Time
+	source string
+	model  string
+}
+
+func extractMeta(path, name string) *fileMeta {
+	t, src, model := extractTimestamp(path, name)
+	return &fileMeta{t, src, model}
+}
+
+// extractTimestamp figures out when a media file was captured. It tries
+// embedded metadata first (EXIF for photos, container atoms for videos),
+// then a date embedded in the filename, and finally falls back to the
+// file's mtime. name is the file's original basename — path may point at
+// a spooled temp file when the source is an archive entry. It returns
+// the timestamp, which method produced it, and the camera model when
+// EXIF had one.
+func extractTimestamp(path, name string) (time.Time, string, string) {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case ext == ".jpg" || ext == ".jpeg":
+		if x, err := jpegExifInfo(path); err == nil {
+			return applyCameraOffset(x), dateFromExif, x.model
+		}
+	case ext == ".tif" || ext == ".tiff" || ext == ".dng" || ext == ".cr2" ||
+		ext == ".nef" || ext == ".arw" || ext == ".orf" || ext == ".rw2":
+		if x, err := tiffExifInfo(path); err == nil {
+			return applyCameraOffset(x), dateFromExif, x.model
+		}
+	case ext == ".heic" || ext == ".heif" || ext == ".cr3" ||
+		ext == ".avif" || ext == ".jxl":
+		if x, err := bmffExifInfo(path); err == nil {
+			return applyCameraOffset(x), dateFromExif, x.model
+		}
+	case ext == ".mkv" || ext == ".webm":
+		if t, err := mkvDateUTC(path); err == nil {
+			return t, dateFromVideo, ""
+		}
+	case ext == ".mts" || ext == ".m2ts":
+		if t, err := avchdDateTime(path); err == nil {
+			return t, dateFromVideo, ""
+		}
+	case videoExts[ext]:
+		if t, err := mp4CreationTime(path); err == nil {
+			return t, dateFromVideo, ""
+		}
+	}
+	if t, ok := filenameTime(name); ok {
+		return t, dateFromFilename, ""
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime(), dateFromModTime, ""
+	}
+	return time.Time{}, dateFromModTime, ""
+}
+
+var errNoDate = errors.New("no date found")
+
+// exifInfo is what copysort cares about from an EXIF block.
+type exifInfo struct {
+	time   time.Time
+	model  string // camera model (tag 0x0110)
+	serial string // body serial number (tag 0xA431)
+}
+
+// jpegExifInfo walks the JPEG marker segments looking for the APP1 Exif
+// payload and parses it as TIFF.
+func jpegExifInfo(path string) (exifInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return exifInfo{}, err
+	}
+	defer f.Close()
+
+	var hdr [2]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil || hdr != [2]byte{0xFF, 0xD8} {
+		return exifInfo{}, errNoDate
+	}
+	for {
+		var mk [4]byte
+		if _, err := io.ReadFull(f, mk[:]); err != nil {
+			return exifInfo{}, errNoDate
+		}
+		if mk[0] != 0xFF {
+			return exifInfo{}, errNoDate
+		}
+		seglen := int(binary.BigEndian.Uint16(mk[2:4])) - 2
+		if seglen < 0 {
+			return exifInfo{}, errNoDate
+		}
+		if mk[1] == 0xE1 { // APP1
+			buf := make([]byte, seglen)
+			if _, err := io.ReadFull(f, buf); err != nil {
+				return exifInfo{}, errNoDate
+			}
+			if len(buf) > 6 && string(buf[:6]) == "Exif\x00\x00" {
+				return parseTIFF(buf[6:])
+			}
+			continue
+		}
+		if mk[1] == 0xDA { // start of scan; no more metadata segments
+			return exifInfo{}, errNoDate
+		}
+		if _, err := f.Seek(int64(seglen), io.SeekCurrent); err != nil {
+			return exifInfo{}, errNoDate
+		}
+	}
+}
+
+// tiffExifInfo parses files that begin with a bare TIFF header (TIFF
+// itself and most TIFF-derived raw formats).
+func tiffExifInfo(path string) (exifInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return exifInfo{}, err
+	}
+	defer f.Close()
+	buf := make([]byte, 256*1024)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return exifInfo{}, err
+	}
+	return parseTIFF(buf[:n])
+}
+
+// bmffExifInfo handles ISO-BMFF stills (HEIC/HEIF, AVIF, JXL, CR3). Properly walking
+// the meta/iloc boxes is a lot of machinery for one tag, so instead scan a
+// bounded prefix for the embedded Exif payload's TIFF header.
+func bmffExifInfo(path string) (exifInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return exifInfo{}, err
+	}
+	defer f.Close()
+	buf := make([]byte, 4*1024*1024)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return exifInfo{}, err
+	}
+	buf = buf[:n]
+	for i := 0; ; {
+		j := strings.Index(string(buf[i:]), "Exif\x00\x00")
+		if j < 0 {
+			return exifInfo{}, errNoDate
+		}
+		i += j + 6
+		if x, err := parseTIFF(buf[i:]); err == nil {
+			return x, nil
+		}
+		// JXL's Exif box holds a 4-byte offset before the TIFF header,
+		// so the match above may have been the box type plus the first
+		// half of a zero offset; retry past the other half.
+		if i+2 <= len(buf) {
+			if x, err := parseTIFF(buf[i+2:]); err == nil {
+				return x, nil
+			}
+		}
+	}
+}
+
+// parseTIFF parses a TIFF structure and returns DateTimeOriginal from the
+// Exif sub-IFD (falling back to DateTime from IFD0) plus the camera
+// model and body serial number when present.
+func parseTIFF(b []byte) (exifInfo, error) {
+	if len(b) < 8 {
+		return exifInfo{}, errNoDate
+	}
+	var bo binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return exifInfo{}, errNoDate
+	}
+	if bo.Uint16(b[2:4]) != 42 {
+		return exifInfo{}, errNoDate
+	}
+	ifd0 := bo.Uint32(b[4:8])
+
+	var info exifInfo
+	var dateTime, dateTimeOriginal, subSec, offset string
+	var exifIFD uint32
+	readIFD := func(off uint32) {
+		if int(off)+2 > len(b) {
+			return
+		}
+		count := int(bo.Uint16(b[off : off+2]))
+		for i := 0; i < count; i++ {
+			e := int(off) + 2 + i*12
+			if e+12 > len(b) {
+				return
+			}
+			tag := bo.Uint16(b[e : e+2])
+			typ := bo.Uint16(b[e+2 : e+4])
+			n := bo.Uint32(b[e+4 : e+8])
+			if tag == 0x8769 { // Exif IFD pointer
+				exifIFD = bo.Uint32(b[e+8 : e+12])
+				continue
+			}
+			if typ != 2 { // all tags below are ASCII
+				continue
+			}
+			var s string
+			if n <= 4 { // short values are stored inline
+				s = strings.TrimRight(string(b[e+8:e+8+int(n)]), "\x00")
+			} else {
+				voff := int(bo.Uint32(b[e+8 : e+12]))
+				if voff+int(n) > len(b) {
+					continue
+				}
+				s = strings.TrimRight(string(b[voff:voff+int(n)]), "\x00")
+			}
+			switch tag {
+			case 0x0132: // DateTime
+				dateTime = s
+			case 0x9003, 0x9004: // DateTimeOriginal, DateTimeDigitized
+				if dateTimeOriginal == "" || tag == 0x9003 {
+					dateTimeOriginal = s
+				}
+			case 0x9291: // SubSecTimeOriginal
+				subSec = s
+			case 0x9290: // SubSecTime
+				if subSec == "" {
+					subSec = s
+				}
+			case 0x9011: // OffsetTimeOriginal
+				offset = s
+			case 0x9010: // OffsetTime
+				if offset == "" {
+					offset = s
+				}
+			case 0x0110: // Model
+				info.model = s
+			case 0xA431: // BodySerialNumber
+				info.serial = s
+			}
+		}
+	}
+	readIFD(ifd0)
+	if exifIFD != 0 {
+		readIFD(exifIFD)
+	}
+	loc := time.Local
+	if z, err := time.Parse("-07:00", offset); err == nil {
+		loc = z.Location()
+	}
+	for _, s := range []string{dateTimeOriginal, dateTime} {
+		if s == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006:01:02 15:04:05", s, loc)
+		if err != nil {
+			continue
+		}
+		// SubSecTime is decimal fractions of a second ("042" = 42ms),
+		// preserving shot order within a second for fast cameras.
+		if subSec != "" {
+			if frac, err := strconv.ParseFloat("0."+subSec, 64); err == nil {
+				t = t.Add(time.Duration(frac * float64(time.Second)))
+			}
+		}
+		info.time = t
+		return info, nil
+	}
+	return exifInfo{}, errNoDate
+}
+
+// mp4CreationTime walks the top-level boxes of an MP4/MOV file to the
+// moov/mvhd atom and reads its creation_time.
+func mp4CreationTime(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	t, err := findMvhd(f, 0, 1<<40)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
+func findMvhd(f *os.File, start, end int64) (time.Time, error) {
+	pos := start
+	var hdr [8]byte
+	for pos+8 <= end {
+		if _, err := f.ReadAt(hdr[:], pos); err != nil {
+			return time.Time{}, errNoDate
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		typ := string(hdr[4:8])
+		headerLen := int64(8)
+		if size == 1 {
+			var big [8]byte
+			if _, err := f.ReadAt(big[:], pos+8); err != nil {
+				return time.Time{}, errNoDate
+			}
+			size = int64(binary.BigEndian.Uint64(big[:]))
+			headerLen = 16
+		} else if size == 0 {
+			size = end - pos
+		}
+		if size < headerLen {
+			return time.Time{}, errNoDate
+		}
+		switch typ {
+		case "moov":
+			return findMvhd(f, pos+headerLen, pos+size)
+		case "mvhd":
+			var body [20]byte
+			if _, err := f.ReadAt(body[:], pos+headerLen); err != nil {
+				return time.Time{}, errNoDate
+			}
+			var secs int64
+			if body[0] == 1 { // version 1: 64-bit timestamps
+				secs = int64(binary.BigEndian.Uint64(body[4:12]))
+			} else {
+				secs = int64(binary.BigEndian.Uint32(body[4:8]))
+			}
+			if secs == 0 {
+				return time.Time{}, errNoDate
+			}
+			// MP4 epoch is 1904-01-01 UTC.
+			t := time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(secs) * time.Second)
+			if t.Year() < 1980 || t.After(time.Now().AddDate(1, 0, 0)) {
+				return time.Time{}, errNoDate
+			}
+			return t, nil
+		}
+		pos += size
+	}
+	return time.Time{}, errNoDate
+}
+
+// mkvDateUTC reads the Matroska/WebM segment's DateUTC element. A full
+// EBML walk is a lot of machinery for one field, so — like bmffExifInfo
+// — this scans a bounded prefix for the element's ID and sanity-checks
+// what it finds.
+func mkvDateUTC(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	buf := make([]byte, 1024*1024)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return time.Time{}, err
+	}
+	buf = buf[:n]
+	if len(buf) < 4 || binary.BigEndian.Uint32(buf[:4]) != 0x1A45DFA3 {
+		return time.Time{}, errNoDate
+	}
+	// DateUTC is element 0x4461, always an 8-byte signed int of
+	// nanoseconds since the Matroska epoch (2001-01-01 UTC).
+	epoch := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i+11 <= len(buf); i++ {
+		if buf[i] != 0x44 || buf[i+1] != 0x61 || buf[i+2] != 0x88 {
+			continue
+		}
+		ns := int64(binary.BigEndian.Uint64(buf[i+3 : i+11]))
+		t := epoch.Add(time.Duration(ns))
+		if t.Year() < 1995 || t.After(time.Now().AddDate(1, 0, 0)) {
+			continue
+		}
+		return t, nil
+	}
+	return time.Time{}, errNoDate
+}
+
+// avchdDateTime digs the recording timestamp out of an AVCHD (.mts /
+// .m2ts) stream. Camcorders embed it as MDPM ("modified DV pack
+// metadata") inside an H.264 SEI message: after the "MDPM" fourcc comes
+// an entry count and then 5-byte tag+value entries, with tag 0x18
+// holding the BCD year/month and tag 0x19 the BCD day and time.
+func avchdDateTime(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	buf := make([]byte, 2*1024*1024)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return time.Time{}, err
+	}
+	buf = buf[:n]
+	bcd := func(b byte) int { return int(b>>4)*10 + int(b&0x0F) }
+	for i := 0; ; {
+		j := strings.Index(string(buf[i:]), "MDPM")
+		if j < 0 {
+			return time.Time{}, errNoDate
+		}
+		i += j + 4
+		if i >= len(buf) {
+			return time.Time{}, errNoDate
+		}
+		count := int(buf[i])
+		p := i + 1
+		var year, month, day, hour, min, sec int
+		for k := 0; k < count && k < 32 && p+5 <= len(buf); k++ {
+			v := buf[p+1 : p+5]
+			switch buf[p] {
+			case 0x18: // timezone byte, then BCD year and month
+				year = bcd(v[1])*100 + bcd(v[2])
+				month = bcd(v[3])
+			case 0x19: // BCD day, hour, minute, second
+				day, hour, min, sec = bcd(v[0]), bcd(v[1]), bcd(v[2]), bcd(v[3])
+			}
+			p += 5
+		}
+		if year >= 1995 && month >= 1 && month <= 12 && day >= 1 && day <= 31 {
+			return time.Date(year, time.Month(month), day, hour, min, sec, 0, time.Local), nil
+		}
+	}
+}
+
+// filenameTime recognizes the date stamps phones and messengers put in
+// filenames, e.g. IMG_20230714_153012.jpg, PXL_20230714_153012345.jpg,
+// IMG-20230714-WA0001.jpg.
+var filenameDateRe = regexp.MustCompile(`(20\d{2})(\d{2})(\d{2})[-_]?(\d{2})?(\d{2})?(\d{2})?`)
+
+func filenameTime(name string) (time.Time, bool) {
+	m := filenameDateRe.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	atoi := func(s string) int {
+		n := 0
+		for _, c := range s {
+			n = n*10 + int(c-'0')
+		}
+		return n
+	}
+	year, month, day := atoi(m[1]), atoi(m[2]), atoi(m[3])
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+	hour, min, sec := atoi(m[4]), atoi(m[5]), atoi(m[6])
+	if hour > 23 || min > 59 || sec > 59 {
+		hour, min, sec = 0, 0, 0
+	}
+	return time.Date(year, time.Month(month), day, hour, min, sec, 0, time.Local), true
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// buildTIFF assembles a minimal little-endian TIFF in memory: IFD0 with
+// a Model tag and an Exif IFD pointer, the Exif IFD with
+// DateTimeOriginal and an inline SubSecTimeOriginal.
+func buildTIFF() []byte {
+	b := make([]byte, 96)
+	le := binary.LittleEndian
+	copy(b[0:], "II")
+	le.PutUint16(b[2:], 42)
+	le.PutUint32(b[4:], 8) // IFD0 offset
+
+	// IFD0: Model (out-of-line) and the Exif IFD pointer.
+	le.PutUint16(b[8:], 2)
+	le.PutUint16(b[10:], 0x0110) // Model
+	le.PutUint16(b[12:], 2)      // ASCII
+	le.PutUint32(b[14:], 8)
+	le.PutUint32(b[18:], 68)
+	le.PutUint16(b[22:], 0x8769) // Exif IFD pointer
+	le.PutUint16(b[24:], 4)
+	le.PutUint32(b[26:], 1)
+	le.PutUint32(b[30:], 38)
+	le.PutUint32(b[34:], 0) // next IFD
+
+	// Exif IFD: DateTimeOriginal (out-of-line) and SubSecTimeOriginal
+	// (short enough to live inline in the value field).
+	le.PutUint16(b[38:], 2)
+	le.PutUint16(b[40:], 0x9003)
+	le.PutUint16(b[42:], 2)
+	le.PutUint32(b[44:], 20)
+	le.PutUint32(b[48:], 76)
+	le.PutUint16(b[52:], 0x9291)
+	le.PutUint16(b[54:], 2)
+	le.PutUint32(b[56:], 4)
+	copy(b[60:], "250\x00")
+	le.PutUint32(b[64:], 0) // next IFD
+
+	copy(b[68:], "TestCam\x00")
+	copy(b[76:], "2023:07:14 15:30:12\x00")
+	return b
+}
+
+func TestParseTIFF(t *testing.T) {
+	x, err := parseTIFF(buildTIFF())
+	if err != nil {
+		t.Fatalf("parseTIFF: %v", err)
+	}
+	want := time.Date(2023, 7, 14, 15, 30, 12, 250*int(time.Millisecond), time.Local)
+	if !x.time.Equal(want) {
+		t.Errorf("time = %v, want %v", x.time, want)
+	}
+	if x.model != "TestCam" {
+		t.Errorf("model = %q, want %q", x.model, "TestCam")
+	}
+}
+
+func TestParseTIFFRejectsGarbage(t *testing.T) {
+	cases := map[string][]byte{
+		"empty":      nil,
+		"too short":  []byte("II"),
+		"bad order":  []byte("XX\x2a\x00\x08\x00\x00\x00"),
+		"bad magic":  []byte("II\x2b\x00\x08\x00\x00\x00"),
+		"ifd beyond": []byte("II\x2a\x00\xff\xff\x00\x00"),
+	}
+	for name, b := range cases {
+		if _, err := parseTIFF(b); err == nil {
+			t.Errorf("%s: parseTIFF accepted invalid input", name)
+		}
+	}
+}
+
+func TestFilenameTime(t *testing.T) {
+	tests := []struct {
+		name string
+		want time.Time
+		ok   bool
+	}{
+		{"IMG_20230714_153012.jpg", time.Date(2023, 7, 14, 15, 30, 12, 0, time.Local), true},
+		{"PXL_20230714_153012345.jpg", time.Date(2023, 7, 14, 15, 30, 12, 0, time.Local), true},
+		{"IMG-20230714-WA0001.jpg", time.Date(2023, 7, 14, 0, 0, 0, 0, time.Local), true},
+		{"20231231.jpg", time.Date(2023, 12, 31, 0, 0, 0, 0, time.Local), true},
+		// An impossible clock falls back to midnight, an impossible
+		// calendar date is no match at all.
+		{"VID_20230714_991299.mp4", time.Date(2023, 7, 14, 0, 0, 0, 0, time.Local), true},
+		{"IMG_20231340_120000.jpg", time.Time{}, false},
+		{"holiday.jpg", time.Time{}, false},
+	}
+	for _, tc := range tests {
+		got, ok := filenameTime(tc.name)
+		if ok != tc.ok || (ok && !got.Equal(tc.want)) {
+			t.Errorf("filenameTime(%q) = %v, %v; want %v, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EXIF cache: repeated dry-runs and re-imports of a mostly unchanged
+// source spend their time re-parsing the same metadata out of the same
+// files. With -exif-cache the result of each extraction — capture time,
+// how it was found, camera model — is kept next to the manifest, keyed
+// by (path, size, mtime) so an edited file misses and is parsed again.
+// Append-only like the hash cache, newest entry for a path winning.
+// Archive entries aren't cached; their spooled paths change every run.
+
+const exifCacheName = ".copysort-exifcache"
+
+type exifCacheEntry struct {
+	size   int64
+	mtime  int64 // unix nanoseconds
+	when   int64 // unix nanoseconds; 0 = no timestamp
+	source string
+	model  string
+}
+
+var exifCache struct {
+	mu      sync.Mutex
+	entries map[string]exifCacheEntry
+	f       *os.File
+}
+
+func initExifCache(destRoot string) error {
+	if !opts.exifCache {
+		return nil
+	}
+	p := filepath.Join(destRoot, exifCacheName)
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open exif cache: %w", err)
+	}
+	exifCache.entries = map[string]exifCacheEntry{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		// when\tsource\tmodel\tsize\tmtime\tpath — path last so it
+		// may contain tabs.
+		parts := strings.SplitN(sc.Text(), "\t", 6)
+		if len(parts) != 6 {
+			continue
+		}
+		when, err1 := strconv.ParseInt(parts[0], 10, 64)
+		size, err2 := strconv.ParseInt(parts[3], 10, 64)
+		mtime, err3 := strconv.ParseInt(parts[4], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		exifCache.entries[parts[5]] = exifCacheEntry{size, mtime, when, parts[1], parts[2]}
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return fmt.Errorf("read exif cache: %w", err)
+	}
+	exifCache.f = f
+	return nil
+}
+
+// metaForJob returns a job's capture metadata, consulting and
+// maintaining the cache when one is loaded.
+func metaForJob(j *job) *fileMeta {
+	if exifCache.entries == nil || j.entryName != "" {
+		return extractMeta(j.srcPath, filepath.Base(j.relPath))
+	}
+	mtime := j.mtime.UnixNano()
+	exifCache.mu.Lock()
+	e, ok := exifCache.entries[j.srcPath]
+	exifCache.mu.Unlock()
+	if ok && e.size == j.size && mtimeMatch(j.srcPath, e.mtime, mtime) {
+		var when time.Time
+		if e.when != 0 {
+			when = time.Unix(0, e.when)
+		}
+		return &fileMeta{when, e.source, e.model}
+	}
+	m := extractMeta(j.srcPath, filepath.Base(j.relPath))
+	when := int64(0)
+	if !m.when.IsZero() {
+		when = m.when.UnixNano()
+	}
+	exifCache.mu.Lock()
+	defer exifCache.mu.Unlock()
+	if _, err := fmt.Fprintf(exifCache.f, "%d\t%s\t%s\t%d\t%d\t%s\n",
+		when, m.source, m.model, j.size, mtime, j.srcPath); err != nil {
+		return m // cache write failures cost a re-parse next run, nothing more
+	}
+	exifCache.entries[j.srcPath] = exifCacheEntry{j.size, mtime, when, m.source, m.model}
+	return m
+}
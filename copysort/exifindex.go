@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With -exif-index every sorted media file's capture timestamp and
+// camera model are appended to .copysort-exifindex in the destination
+// root as it is copied. `report` reads the index instead of re-opening
+// EXIF from tens of thousands of archived files, which turns its camera
+// breakdown from a full re-parse into a table lookup. The file is
+// append-only like the manifest; the last line for a path wins.
+
+const exifIndexName = ".copysort-exifindex"
+
+const exifIndexTimeFmt = "2006-01-02T15:04:05"
+
+var exifIndex struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func initExifIndex(destRoot string) error {
+	if !opts.exifIndex {
+		return nil
+	}
+	f, err := os.OpenFile(filepath.Join(destRoot, exifIndexName),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", exifIndexName, err)
+	}
+	exifIndex.f = f
+	return nil
+}
+
+// recordExifIndex notes where a media file landed and what its metadata
+// said; a zero time leaves the column empty.
+func recordExifIndex(destRel string, when time.Time, model string) {
+	if exifIndex.f == nil {
+		return
+	}
+	ts := ""
+	if !when.IsZero() {
+		ts = when.Format(exifIndexTimeFmt)
+	}
+	exifIndex.mu.Lock()
+	defer exifIndex.mu.Unlock()
+	if _, err := fmt.Fprintf(exifIndex.f, "%s\t%s\t%s\n", destRel, ts, model); err != nil {
+		warnf("%s: %v\n", exifIndexName, err)
+	}
+}
+
+type exifIndexEntry struct {
+	when  time.Time
+	model string
+}
+
+// loadExifIndex reads an archive's index read-only; a missing file is
+// simply an empty map, so callers fall back to parsing EXIF themselves.
+func loadExifIndex(root string) map[string]exifIndexEntry {
+	f, err := os.Open(filepath.Join(root, exifIndexName))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	idx := map[string]exifIndexEntry{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		cols := strings.SplitN(sc.Text(), "\t", 3)
+		if len(cols) != 3 {
+			continue
+		}
+		e := exifIndexEntry{model: cols[2]}
+		if cols[1] != "" {
+			if t, err := time.ParseInLocation(exifIndexTimeFmt, cols[1], time.Local); err == nil {
+				e.when = t
+			}
+		}
+		idx[cols[0]] = e
+	}
+	return idx
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FAT-family filesystems store mtimes at 2-second resolution in local
+// time without a zone, so a timestamp that round-trips through an SD
+// card can come back up to two seconds off with the file untouched.
+// Skip and verify decisions that compare mtimes go through mtimeMatch,
+// which allows the drift when the path lives on such a filesystem;
+// everywhere else equality stays exact, since loosening it would mask
+// real edits.
+
+const fatMtimeSlack = 2 * time.Second
+
+var fatDirs sync.Map // directory -> bool (FAT-family filesystem)
+
+// fatPath reports whether a path sits on a FAT-family filesystem,
+// caching per directory so walks don't statfs every file.
+func fatPath(path string) bool {
+	dir := filepath.Dir(path)
+	if v, ok := fatDirs.Load(dir); ok {
+		return v.(bool)
+	}
+	name, _, _, err := fsInfo(dir)
+	fat := err == nil && fatFS(name)
+	fatDirs.Store(dir, fat)
+	return fat
+}
+
+// mtimeMatch compares two mtimes (unix nanoseconds) for a skip or
+// verify decision about path.
+func mtimeMatch(path string, a, b int64) bool {
+	if a == b {
+		return true
+	}
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	return d <= int64(fatMtimeSlack) && fatPath(path)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMtimeMatch(t *testing.T) {
+	// The test directory is not FAT, so only exact matches pass; the
+	// 2-second slack must stay reserved for FAT volumes.
+	p := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(p, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UnixNano()
+	tests := []struct {
+		a, b int64
+		want bool
+	}{
+		{now, now, true},
+		{now, now + 1, false},
+		{now, now + int64(fatMtimeSlack), false},
+		{now, now + int64(3*time.Second), false},
+		{now + int64(3*time.Second), now, false},
+	}
+	for _, tc := range tests {
+		if got := mtimeMatch(p, tc.a, tc.b); got != tc.want {
+			t.Errorf("mtimeMatch(%d, %d) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// `copysort find DEST NAME...` answers "where did IMG_0042.JPG end up?"
+// after date-sorting scrambled the layout. Each NAME is matched
+// case-insensitively against the manifest: a bare filename matches the
+// basename of the source key or the destination, anything containing a
+// slash must suffix-match the whole path. Every destination the file
+// was ever copied to is printed, including superseded versions.
+
+func runFind(args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: copysort find DEST NAME...")
+		os.Exit(2)
+	}
+	root, names := args[0], args[1:]
+	f, err := os.Open(filepath.Join(root, manifestName))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	found := false
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		src, rest, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		dest, _, _ := strings.Cut(rest, "\t")
+		for _, name := range names {
+			if matchesName(src, name) || matchesName(dest, name) {
+				fmt.Printf("%s -> %s\n", src, dest)
+				found = true
+				break
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	if !found {
+		fmt.Fprintln(os.Stderr, "copysort: no matches")
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// matchesName compares a manifest path against a query: bare names
+// match the basename, names with a slash must suffix-match the path.
+func matchesName(p, name string) bool {
+	p = strings.ToLower(p)
+	name = strings.ToLower(filepath.ToSlash(name))
+	if strings.Contains(name, "/") {
+		return p == name || strings.HasSuffix(p, "/"+name)
+	}
+	return path.Base(p) == name
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// fsInfo names the filesystem a path lives on and reports its free and
+// total bytes; `doctor` uses the name to call out FAT-family quirks.
+func fsInfo(root string) (name string, free, total int64, err error) {
+	var st syscall.Statfs_t
+	if err = syscall.Statfs(root, &st); err != nil {
+		return
+	}
+	names := map[int64]string{
+		0x4d44:     "vfat",
+		0x2011bab0: "exfat",
+		0xef53:     "ext4",
+		0x9123683e: "btrfs",
+		0x58465342: "xfs",
+		0x01021994: "tmpfs",
+		0x5346544e: "ntfs",
+		0x6969:     "nfs",
+		0xff534d42: "cifs",
+		0xfe534d42: "smb2",
+		0x65735546: "fuse",
+	}
+	name = names[int64(st.Type)]
+	if name == "" {
+		name = fmt.Sprintf("type 0x%x", st.Type)
+	}
+	free = int64(st.Bavail) * int64(st.Bsize)
+	total = int64(st.Blocks) * int64(st.Bsize)
+	return
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func fsInfo(root string) (name string, free, total int64, err error) {
+	return "", 0, 0, errors.New("filesystem info not available on this platform")
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GPS stripping: -strip-gps scrubs location tags from copies on their
+// way into the archive, for collections that later get shared or synced
+// to third-party services. The edit happens on the destination file only
+// — sources are never touched — and works in place: the GPS IFD and the
+// values it references are zeroed rather than removed, so every other
+// offset in the file stays valid and no re-encode is needed. JPEG and
+// TIFF-headered files (including most raws) are handled; formats whose
+// EXIF lives in ISO-BMFF boxes (HEIC, CR3) are left alone.
+
+// tiffTypeSizes maps TIFF field types to their per-element byte size.
+var tiffTypeSizes = [13]uint32{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+// stripGPSExt gates the scrub to still-image extensions so videos and
+// documents aren't reopened after every copy.
+func stripGPSExt(destPath string) bool {
+	ext := strings.ToLower(filepath.Ext(destPath))
+	return photoExts[ext] || rawExts[ext]
+}
+
+// stripGPS zeroes the GPS IFD in path's EXIF block, editing the file in
+// place. It reports whether anything was removed; unsupported or
+// EXIF-less files return (false, nil).
+func stripGPS(path string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var hdr [4]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return false, nil
+	}
+	switch {
+	case hdr[0] == 0xFF && hdr[1] == 0xD8:
+		return stripGPSJPEG(f)
+	case string(hdr[:2]) == "II" || string(hdr[:2]) == "MM":
+		return stripGPSTIFF(f)
+	}
+	return false, nil
+}
+
+// stripGPSJPEG walks the marker segments to the Exif APP1 block and
+// scrubs its TIFF payload, writing the segment back if anything changed.
+func stripGPSJPEG(f *os.File) (bool, error) {
+	if _, err := f.Seek(2, io.SeekStart); err != nil {
+		return false, err
+	}
+	for {
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return false, err
+		}
+		var mk [4]byte
+		if _, err := io.ReadFull(f, mk[:]); err != nil {
+			return false, nil
+		}
+		if mk[0] != 0xFF {
+			return false, nil
+		}
+		seglen := int(binary.BigEndian.Uint16(mk[2:4])) - 2
+		if seglen < 0 {
+			return false, nil
+		}
+		if mk[1] == 0xE1 { // APP1
+			buf := make([]byte, seglen)
+			if _, err := io.ReadFull(f, buf); err != nil {
+				return false, nil
+			}
+			if len(buf) > 6 && string(buf[:6]) == "Exif\x00\x00" {
+				if !scrubTIFFGPS(buf[6:]) {
+					return false, nil
+				}
+				if _, err := f.WriteAt(buf, pos+4); err != nil {
+					return false, err
+				}
+				return true, nil
+			}
+			continue
+		}
+		if mk[1] == 0xDA { // start of scan; no more metadata segments
+			return false, nil
+		}
+		if _, err := f.Seek(int64(seglen), io.SeekCurrent); err != nil {
+			return false, nil
+		}
+	}
+}
+
+// stripGPSTIFF scrubs a file that begins with a bare TIFF header. The
+// IFDs of interest sit near the front, so a bounded prefix is enough —
+// the same assumption tiffExifInfo makes when reading dates.
+func stripGPSTIFF(f *os.File) (bool, error) {
+	buf := make([]byte, 1024*1024)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	buf = buf[:n]
+	if !scrubTIFFGPS(buf) {
+		return false, nil
+	}
+	if _, err := f.WriteAt(buf, 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// scrubTIFFGPS finds the GPS IFD pointer (tag 0x8825) in b's IFD chain
+// and zeroes the IFD it points at — entry table, entry count and any
+// out-of-line values. The pointer is left aimed at the now-empty IFD,
+// which readers accept as "no GPS data". Reports whether b was modified.
+func scrubTIFFGPS(b []byte) bool {
+	if len(b) < 8 {
+		return false
+	}
+	var bo binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return false
+	}
+	if bo.Uint16(b[2:4]) != 42 {
+		return false
+	}
+	changed := false
+	off := bo.Uint32(b[4:8])
+	for hops := 0; off != 0 && hops < 8; hops++ {
+		if int(off)+2 > len(b) {
+			break
+		}
+		count := int(bo.Uint16(b[off : off+2]))
+		end := int(off) + 2 + count*12
+		if end+4 > len(b) {
+			break
+		}
+		for i := 0; i < count; i++ {
+			e := int(off) + 2 + i*12
+			if bo.Uint16(b[e:e+2]) == 0x8825 { // GPS IFD pointer
+				zeroIFD(b, bo, bo.Uint32(b[e+8:e+12]))
+				changed = true
+			}
+		}
+		off = bo.Uint32(b[end : end+4])
+	}
+	return changed
+}
+
+// zeroIFD overwrites the IFD at off with zeros: each entry's out-of-line
+// value, the entries themselves, the entry count and the next-IFD
+// pointer. Every offset it touches is bounds-checked so a corrupt file
+// can't push the scrub outside the buffer.
+func zeroIFD(b []byte, bo binary.ByteOrder, off uint32) {
+	if int(off)+2 > len(b) {
+		return
+	}
+	count := int(bo.Uint16(b[off : off+2]))
+	for i := 0; i < count; i++ {
+		e := int(off) + 2 + i*12
+		if e+12 > len(b) {
+			break
+		}
+		typ := bo.Uint16(b[e+2 : e+4])
+		n := bo.Uint32(b[e+4 : e+8])
+		if int(typ) < len(tiffTypeSizes) {
+			if sz := tiffTypeSizes[typ] * n; sz > 4 {
+				v := int(bo.Uint32(b[e+8 : e+12]))
+				if v >= 0 && v+int(sz) <= len(b) {
+					clear(b[v : v+int(sz)])
+				}
+			}
+		}
+		clear(b[e : e+12])
+	}
+	if end := int(off) + 2 + count*12; end+4 <= len(b) {
+		clear(b[end : end+4])
+	}
+	clear(b[off : off+2])
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// buildGPSTIFF assembles a little-endian TIFF whose IFD0 holds a GPS
+// IFD pointer; the GPS IFD carries one rational latitude entry with its
+// values out of line.
+func buildGPSTIFF() []byte {
+	b := make([]byte, 60)
+	le := binary.LittleEndian
+	copy(b[0:], "II")
+	le.PutUint16(b[2:], 42)
+	le.PutUint32(b[4:], 8)
+
+	le.PutUint16(b[8:], 1)
+	le.PutUint16(b[10:], 0x8825) // GPS IFD pointer
+	le.PutUint16(b[12:], 4)
+	le.PutUint32(b[14:], 1)
+	le.PutUint32(b[18:], 26)
+	le.PutUint32(b[22:], 0) // next IFD
+
+	le.PutUint16(b[26:], 1)
+	le.PutUint16(b[28:], 0x0002) // GPSLatitude
+	le.PutUint16(b[30:], 5)      // rational
+	le.PutUint32(b[32:], 2)
+	le.PutUint32(b[36:], 44)
+	le.PutUint32(b[40:], 0) // next IFD
+	for i := 44; i < 60; i++ {
+		b[i] = 0xAA // the coordinate payload
+	}
+	return b
+}
+
+func TestScrubTIFFGPS(t *testing.T) {
+	b := buildGPSTIFF()
+	if !scrubTIFFGPS(b) {
+		t.Fatal("scrubTIFFGPS reported no change on a TIFF with a GPS IFD")
+	}
+	if !bytes.Equal(b[44:60], make([]byte, 16)) {
+		t.Error("out-of-line GPS values not zeroed")
+	}
+	if !bytes.Equal(b[26:44], make([]byte, 18)) {
+		t.Error("GPS IFD entries not zeroed")
+	}
+	if binary.LittleEndian.Uint16(b[10:12]) != 0x8825 {
+		t.Error("IFD0 must keep its (now harmless) GPS pointer entry")
+	}
+	// The scrub is idempotent and must never report phantom changes.
+	if !scrubTIFFGPS(b) {
+		t.Error("second scrub should still find the pointer entry")
+	}
+}
+
+func TestScrubTIFFGPSUntouched(t *testing.T) {
+	noGPS := buildTIFF()
+	before := append([]byte(nil), noGPS...)
+	if scrubTIFFGPS(noGPS) {
+		t.Error("scrubTIFFGPS reported a change on a TIFF without GPS")
+	}
+	if !bytes.Equal(noGPS, before) {
+		t.Error("GPS-less TIFF was modified")
+	}
+	for _, b := range [][]byte{nil, []byte("II"), []byte("ZZ\x2a\x00\x08\x00\x00\x00")} {
+		if scrubTIFFGPS(b) {
+			t.Errorf("scrubTIFFGPS accepted invalid input %q", b)
+		}
+	}
+}
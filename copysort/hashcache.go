@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hash cache: what makes -manifest-key=hash expensive is re-reading
+// every source on every run just to recognize it. The cache, kept next
+// to the manifest in the destination root, maps (path, size, mtime) to
+// the sha256 computed last time; a file whose size or mtime changed
+// misses and is hashed again. Like the manifest it is append-only, with
+// the newest entry for a path winning on load.
+
+const hashCacheName = ".copysort-hashcache"
+
+type hashCacheEntry struct {
+	size  int64
+	mtime int64 // unix nanoseconds
+	sum   string
+}
+
+var hashCache struct {
+	mu      sync.Mutex
+	entries map[string]hashCacheEntry
+	f       *os.File
+}
+
+func initHashCache(destRoot string) error {
+	if opts.manifestKey != "hash" {
+		return nil
+	}
+	p := filepath.Join(destRoot, hashCacheName)
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open hash cache: %w", err)
+	}
+	hashCache.entries = map[string]hashCacheEntry{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		// sum\tsize\tmtime\tpath — path last so it may contain tabs.
+		parts := strings.SplitN(sc.Text(), "\t", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		size, err1 := strconv.ParseInt(parts[1], 10, 64)
+		mtime, err2 := strconv.ParseInt(parts[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		hashCache.entries[parts[3]] = hashCacheEntry{size, mtime, parts[0]}
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return fmt.Errorf("read hash cache: %w", err)
+	}
+	hashCache.f = f
+	return nil
+}
+
+// hashSource returns the sha256 of a source file, consulting and
+// maintaining the cache when one is loaded.
+func hashSource(path string, size int64, mtime time.Time) (string, error) {
+	if hashCache.f == nil {
+		return hashFile(path)
+	}
+	hashCache.mu.Lock()
+	e, ok := hashCache.entries[path]
+	hashCache.mu.Unlock()
+	if ok && e.size == size && mtimeMatch(path, e.mtime, mtime.UnixNano()) {
+		return e.sum, nil
+	}
+	sum, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+	hashCache.mu.Lock()
+	defer hashCache.mu.Unlock()
+	if _, err := fmt.Fprintf(hashCache.f, "%s\t%d\t%d\t%s\n", sum, size, mtime.UnixNano(), path); err != nil {
+		return sum, nil // cache write failures cost a re-hash next run, nothing more
+	}
+	hashCache.entries[path] = hashCacheEntry{size, mtime.UnixNano(), sum}
+	return sum, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Per-file hooks: -hook SCRIPT execs a user script at two points for every
+// file, keeping niche workflows (push to Immich, run exiftool, custom
+// routing rules) out of the core. The script receives the phase as its
+// single argument and the file's metadata in COPYSORT_* environment
+// variables:
+//
+//	route  before the copy; stdout may override the decision:
+//	       "skip" drops the file, any other non-empty first line
+//	       replaces the destination path (relative to DEST)
+//	done   after a successful copy, with COPYSORT_DEST set to the
+//	       final path on disk
+//
+// A non-zero exit in the route phase fails the file; in the done phase it
+// is reported but the copy stands — the file is already safely archived.
+
+var hook struct {
+	script string
+}
+
+func initHook(script string) error {
+	if script == "" {
+		return nil
+	}
+	info, err := os.Stat(script)
+	if err != nil {
+		return fmt.Errorf("-hook: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("-hook: %s is a directory", script)
+	}
+	hook.script = script
+	return nil
+}
+
+func hookEnv(j *job, destRel, dateSource, model string, when time.Time) []string {
+	env := append(os.Environ(),
+		"COPYSORT_SRC="+j.srcPath,
+		"COPYSORT_REL="+j.relPath,
+		"COPYSORT_DEST_REL="+destRel,
+		"COPYSORT_SIZE="+strconv.FormatInt(j.size, 10),
+		"COPYSORT_CATEGORY="+category(j.relPath),
+	)
+	if dateSource != "" {
+		env = append(env,
+			"COPYSORT_DATE="+when.Format(time.RFC3339),
+			"COPYSORT_DATE_SOURCE="+dateSource,
+		)
+	}
+	if model != "" {
+		env = append(env, "COPYSORT_MODEL="+model)
+	}
+	return env
+}
+
+// hookRoute runs the route phase and returns the (possibly overridden)
+// destination path and whether the hook asked to skip the file.
+func hookRoute(j *job, destRel, dateSource, model string, when time.Time) (string, bool, error) {
+	cmd := exec.Command(hook.script, "route")
+	cmd.Env = hookEnv(j, destRel, dateSource, model, when)
+	var outb, errb bytes.Buffer
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(errb.String()); msg != "" {
+			return "", false, fmt.Errorf("hook: %s", msg)
+		}
+		return "", false, fmt.Errorf("hook: %w", err)
+	}
+	line, _, _ := strings.Cut(outb.String(), "\n")
+	switch line = strings.TrimSpace(line); line {
+	case "":
+		return destRel, false, nil
+	case "skip":
+		return "", true, nil
+	default:
+		return line, false, nil
+	}
+}
+
+// hookDone runs the done phase after a file has landed at destPath.
+func hookDone(j *job, destPath string) {
+	cmd := exec.Command(hook.script, "done")
+	cmd.Env = append(os.Environ(),
+		"COPYSORT_SRC="+j.srcPath,
+		"COPYSORT_REL="+j.relPath,
+		"COPYSORT_DEST="+destPath,
+		"COPYSORT_SIZE="+strconv.FormatInt(j.size, 10),
+		"COPYSORT_CATEGORY="+category(j.relPath),
+	)
+	var errb bytes.Buffer
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(errb.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		errorf("hook done %s: %s\n", j.srcPath, msg)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Leveled logging: every message on the copy path goes through one of
+// debugf/infof/warnf/errorf. The console level defaults to info, -quiet
+// raises it to warn and -verbose lowers it to debug (which logs every
+// skip decision and destination computation — the thing to read when a
+// file sorted somewhere surprising). -log-file additionally appends every
+// message, timestamped and levelled, regardless of the console level.
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error"}
+
+var logger struct {
+	mu      sync.Mutex
+	file    *os.File
+	console logLevel
+}
+
+func initLog(path string) error {
+	logger.console = levelInfo
+	if opts.quiet {
+		logger.console = levelWarn
+	}
+	if opts.verbose {
+		logger.console = levelDebug
+	}
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("-log-file: %w", err)
+	}
+	logger.file = f
+	return nil
+}
+
+func closeLog() {
+	if logger.file != nil {
+		logger.file.Close()
+	}
+}
+
+func logAt(lv logLevel, format string, a ...any) {
+	msg := strings.TrimRight(fmt.Sprintf(format, a...), "\n")
+	if logger.file != nil {
+		logger.mu.Lock()
+		fmt.Fprintf(logger.file, "%s %-5s %s\n",
+			time.Now().Format("2006-01-02 15:04:05"), levelNames[lv], msg)
+		logger.mu.Unlock()
+	}
+	if lv < logger.console {
+		return
+	}
+	if lv >= levelWarn {
+		msg = levelNames[lv] + ": " + msg
+	}
+	if tuiActive() {
+		tui.events <- evLog{msg}
+		return
+	}
+	if lv >= levelWarn {
+		fmt.Fprintln(os.Stderr, msg)
+		return
+	}
+	fmt.Println(msg)
+}
+
+func debugf(format string, a ...any) { logAt(levelDebug, format, a...) }
+func infof(format string, a ...any)  { logAt(levelInfo, format, a...) }
+func warnf(format string, a ...any)  { logAt(levelWarn, format, a...) }
+func errorf(format string, a ...any) { logAt(levelError, format, a...) }
@@ -0,0 +1,575 @@
+// Command copysort copies photos and videos from one or more source trees
+// (SD cards, phone dumps, old backups) into a destination archive sorted by
+// capture date. Media files land in YYYY/MM/DD folders based on embedded
+// metadata; everything else is copied into an "unsorted" tree that mirrors
+// the source layout. A manifest in the destination root records what has
+// already been imported so repeated runs only copy new files.
+//
+// Usage:
+//
+//	copysort [flags] SRC [SRC...] DEST
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type options struct {
+	workers          int
+	dryRun           bool
+	verbose          bool
+	template         string
+	normalize        string // "", "nfc" or "nfd"
+	fsync            bool
+	livePhotos       bool
+	rawPolicy        string // keep, raw-only, jpeg-only or split
+	groupBursts      bool
+	appleExports     bool
+	order            string // job scheduling policy
+	yes              bool
+	reportFallbacks  bool
+	encrypt          string // e.g. "age:RECIPIENT" or "gpg:KEYID"
+	compress         string // gzip or zstd, generic tree only
+	sha256sums       bool
+	xattrSums        bool
+	exifIndex        bool
+	archiveID        string // expected .copysort-archive marker content
+	force            bool
+	runLog           string // checkpoint log file; "" = off
+	checkpoint       time.Duration
+	sample           int // copy only N random pending files; 0 = all
+	autoWorkers      bool
+	minWorkers       int // lower bound for -auto-workers scaling
+	iouring          bool
+	exifCache        bool
+	plan             bool
+	verifySample     string // percentage of copies to re-read; "" = off
+	sniff            bool
+	precheck         bool
+	resume           bool
+	mqtt             string
+	mqttTopic        string
+	mirror           bool
+	trash            bool
+	tui              bool
+	cameraOffsets    string
+	dupePolicy       string // "" or keep-largest
+	phash            bool
+	videoThumbs      string // "", "all" or comma list of extensions
+	convert          string // "" or "heic=jpeg"
+	keepOriginals    bool
+	hook             string // per-file hook script
+	upload           string // e.g. "immich:https://photos.example.com"
+	albums           string // "", "link" or "index"
+	followSymlinks   bool
+	preserveSymlinks bool
+	retries          int
+	strict           bool
+	quiet            bool
+	logFile          string
+	pprofAddr        string
+	bench            bool
+	exifWorkers      int
+	copyBuffer       string
+	odirect          bool
+	includeHidden    bool
+	minSize          string
+	maxSize          string
+	monthNames       string
+	granularity      string
+	classify         bool
+	noOverwrite      bool
+	birthtime        bool
+	mtime            string // source or capture
+	stripGPS         bool
+	routes           string // per-extension destination routing file
+	manifestKey      string // path or hash
+	progressSocket   string
+	tarMode          string // "", single or year
+	rename           string // filename template for sorted media
+	tag              string // import-session label
+	assertReadonly   bool
+	plain            bool
+	vss              bool
+	skipReport       string // audit file for everything not copied
+	verifySkips      string // "", size or hash
+	minRating        int    // XMP star-rating floor; 0 = no filter
+	keyword          string // required XMP/IPTC keyword
+	copyAhead        string // staging queue size; "" = reads not decoupled
+	snapshot         bool
+	maxDepth         int        // walk depth limit; 0 = unlimited
+	excludeDirs      stringList // directory names skipped anywhere in the walk
+	spill            stringList // extra destination roots
+	spillPolicy      string     // fill-first or by-year
+	autoThrottle     bool
+}
+
+// stringList lets a flag be given more than once.
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+var opts options
+
+func main() {
+	flag.IntVar(&opts.workers, "workers", 8, "number of copy workers")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "scan and report without copying anything")
+	flag.BoolVar(&opts.verbose, "verbose", false, "log every file as it is processed")
+	flag.StringVar(&opts.template, "template", defaultTemplate, "destination path template for sorted media")
+	flag.StringVar(&opts.normalize, "normalize", "", "normalize destination filenames to the given unicode form (nfc or nfd)")
+	flag.BoolVar(&opts.fsync, "fsync", false, "fsync each destination file and its directory before recording it in the manifest")
+	flag.BoolVar(&opts.livePhotos, "live-photos", true, "keep Live Photo image+.mov pairs together in the still's date folder")
+	flag.StringVar(&opts.rawPolicy, "raw-policy", "keep", "RAW+JPEG pair handling: keep, raw-only, jpeg-only or split (RAW/ and JPEG/ subfolders)")
+	flag.BoolVar(&opts.groupBursts, "group-bursts", false, "place detected bursts/brackets in a per-burst subfolder of the date directory")
+	flag.BoolVar(&opts.appleExports, "apple-exports", false, "fold Photos export quirks: drop \"(1)\" re-exports and JPEG halves of HEIC+JPEG pairs, carry .AAE edits as companions")
+	flag.StringVar(&opts.order, "order", "source", "job scheduling: source, smallest-first, largest-first or photos-first")
+	flag.BoolVar(&opts.yes, "yes", false, "skip the pre-copy confirmation prompt")
+	flag.BoolVar(&opts.reportFallbacks, "report-fallbacks", false, "list every file dated from its filename or mtime instead of embedded metadata")
+	flag.StringVar(&opts.encrypt, "encrypt", "", "encrypt destination files via age:RECIPIENT[,..] or gpg:KEYID")
+	flag.StringVar(&opts.compress, "compress", "", "compress non-media files in the unsorted tree (gzip or zstd)")
+	flag.BoolVar(&opts.sha256sums, "sha256sums", false, "record copied files in a sha256sum-compatible SHA256SUMS file in the destination")
+	flag.BoolVar(&opts.xattrSums, "xattr-sums", false, "store each copied file's sha256 in a user.copysort.sha256 xattr on the destination (linux only)")
+	flag.BoolVar(&opts.exifIndex, "exif-index", false, "append capture time and camera of each sorted media file to .copysort-exifindex for fast report queries")
+	flag.StringVar(&opts.archiveID, "archive-id", "", "stamp and check a .copysort-archive marker in the destination; refuse to run on a missing or different ID")
+	flag.BoolVar(&opts.force, "force", false, "run despite a missing or mismatched -archive-id marker")
+	flag.StringVar(&opts.runLog, "run-log", "", "append periodic progress checkpoints (files, bytes, rate, errors) to FILE")
+	flag.DurationVar(&opts.checkpoint, "checkpoint", 5*time.Minute, "interval between -run-log checkpoint lines")
+	flag.IntVar(&opts.sample, "sample", 0, "copy only N randomly chosen pending files, to validate templates and filters before a full import")
+	flag.BoolVar(&opts.autoWorkers, "auto-workers", false, "scale the active worker count between -min-workers and -workers by observed throughput")
+	flag.IntVar(&opts.minWorkers, "min-workers", 2, "lower bound for -auto-workers scaling")
+	flag.BoolVar(&opts.iouring, "iouring", false, "copy through io_uring, overlapping reads and writes per file (linux 5.6+)")
+	flag.BoolVar(&opts.exifCache, "exif-cache", false, "cache capture-time extraction by (path, size, mtime) so repeat runs don't re-parse unchanged sources")
+	flag.BoolVar(&opts.plan, "plan", false, "apply the template to all pending files and report collisions, date fallbacks and over-long paths, copying nothing")
+	flag.StringVar(&opts.verifySample, "verify-sample", "", "re-read and hash this percentage of copied files after the run (e.g. 5%)")
+	flag.BoolVar(&opts.sniff, "sniff", false, "classify files by content signatures, correcting wrong or missing extensions")
+	flag.BoolVar(&opts.precheck, "precheck", false, "stat computed destinations up front, dropping already-copied files from the totals and reporting conflicts early")
+	flag.BoolVar(&opts.resume, "resume", false, "save the scanned job list and resume from it after a crash instead of rescanning")
+	flag.StringVar(&opts.mqtt, "mqtt", "", "publish progress events to this MQTT broker (tcp://host:1883)")
+	flag.StringVar(&opts.mqttTopic, "mqtt-topic", "copysort", "base MQTT topic; events publish retained to TOPIC/start, TOPIC/progress, TOPIC/done")
+	flag.BoolVar(&opts.mirror, "mirror", false, "prune unsorted-tree files whose source counterpart was removed")
+	flag.BoolVar(&opts.trash, "trash", false, "with -mirror, move pruned files into .copysort-trash instead of deleting")
+	flag.BoolVar(&opts.tui, "tui", false, "interactive TUI with per-worker activity, throughput graph and pause/resume keys")
+	flag.StringVar(&opts.cameraOffsets, "camera-offsets", "", "file of per-camera clock offsets (\"model or serial = offset\" lines)")
+	flag.StringVar(&opts.dupePolicy, "dupe-policy", "", "near-duplicate handling: keep-largest quarantines the smaller of two same-shot files")
+	flag.BoolVar(&opts.phash, "phash", false, "perceptual-hash pass reporting visually identical images after the run")
+	flag.StringVar(&opts.videoThumbs, "video-thumbs", "", "generate JPEG poster frames for sorted videos via ffmpeg (\"all\" or e.g. \"mov,avi\")")
+	flag.StringVar(&opts.convert, "convert", "", "transcode during copy; currently heic=jpeg")
+	flag.BoolVar(&opts.keepOriginals, "keep-originals", false, "with -convert, also archive the untouched original under originals/")
+	flag.StringVar(&opts.hook, "hook", "", "script exec'd per file for custom routing and post-copy actions (see hook.go)")
+	flag.StringVar(&opts.upload, "upload", "", "also push sorted media to a server: immich:URL (API key in $IMMICH_API_KEY)")
+	flag.StringVar(&opts.albums, "albums", "", "record source folder names as albums: link (symlinked albums/ tree) or index (sidecar file)")
+	flag.BoolVar(&opts.followSymlinks, "follow-symlinks", false, "descend into symlinked directories and copy symlinked files' targets (with loop detection)")
+	flag.BoolVar(&opts.preserveSymlinks, "preserve-symlinks", false, "recreate source symlinks in the unsorted tree instead of skipping them")
+	flag.IntVar(&opts.retries, "retries", 2, "times to retry a file after a transient I/O error (EIO, stale handle, timeout)")
+	flag.BoolVar(&opts.strict, "strict", false, "abort the run on the first error instead of continuing")
+	flag.BoolVar(&opts.quiet, "quiet", false, "only print warnings and errors")
+	flag.StringVar(&opts.logFile, "log-file", "", "append all messages (including debug) to this file")
+	flag.StringVar(&opts.pprofAddr, "pprof", "", "serve pprof handlers on this address (e.g. :6060)")
+	flag.BoolVar(&opts.bench, "bench", false, "measure walk, EXIF and read throughput over the sources without copying")
+	flag.IntVar(&opts.exifWorkers, "exif-workers", 4, "number of metadata-extraction workers feeding the copy pool")
+	flag.StringVar(&opts.copyBuffer, "copy-buffer", "1M", "size of the pooled copy buffers (e.g. 512K, 4M)")
+	flag.BoolVar(&opts.odirect, "odirect", false, "write files over 64M with O_DIRECT to spare the page cache (linux only)")
+	flag.BoolVar(&opts.includeHidden, "include-hidden", false, "also copy hidden files and directories (dotfiles); OS junk like Thumbs.db is always skipped")
+	flag.StringVar(&opts.minSize, "min-size", "", "skip files smaller than this (e.g. 10K)")
+	flag.StringVar(&opts.maxSize, "max-size", "", "skip files larger than this (e.g. 2G)")
+	flag.StringVar(&opts.monthNames, "month-names", "", "12 comma-separated names for the {monthname} template field (for localization)")
+	flag.StringVar(&opts.granularity, "granularity", "", "date-tree depth preset: year, quarter, month, week or day (instead of -template)")
+	flag.BoolVar(&opts.classify, "classify", true, "route screenshots and WhatsApp media into screenshots/ and whatsapp/ date trees")
+	flag.BoolVar(&opts.noOverwrite, "no-overwrite", false, "never replace an existing destination file; report the conflict instead")
+	flag.BoolVar(&opts.birthtime, "birthtime", true, "set sorted files' creation time to the capture timestamp where the platform allows (macOS, Windows)")
+	flag.StringVar(&opts.mtime, "mtime", "source", "destination mtime for sorted media: source (preserve) or capture (the extracted timestamp)")
+	flag.BoolVar(&opts.stripGPS, "strip-gps", false, "remove GPS tags from copied photos (sources are left untouched)")
+	flag.StringVar(&opts.routes, "routes", "", "file routing extension groups to other destination roots (\"exts = root\" lines)")
+	flag.StringVar(&opts.manifestKey, "manifest-key", "path", "manifest key: path, or hash to recognize renamed/moved sources by content")
+	flag.StringVar(&opts.progressSocket, "progress-socket", "", "stream JSON progress events to this unix socket (or existing named pipe)")
+	flag.StringVar(&opts.tarMode, "tar", "", "write tar archives instead of a directory tree: single, or year (one per capture year)")
+	flag.StringVar(&opts.rename, "rename", "", "rename sorted media by template, e.g. \"{datetime}_{camera}_{orig}\" (also {date}, {time})")
+	flag.StringVar(&opts.tag, "tag", "", "label this import session in the manifest and per-folder import.json files")
+	flag.BoolVar(&opts.assertReadonly, "assert-readonly", false, "refuse to run if a source overlaps the destination or shares its volume")
+	flag.BoolVar(&opts.plain, "plain", false, "periodic status lines instead of the progress bar (implied when stdout is not a terminal)")
+	flag.BoolVar(&opts.plain, "no-progress", false, "alias for -plain")
+	flag.BoolVar(&opts.vss, "vss", false, "walk a Volume Shadow Copy of each source so locked files can be read (windows only)")
+	flag.StringVar(&opts.skipReport, "skip-report", "", "write every scanned-but-not-copied file and its reason to FILE (.csv or .json)")
+	flag.StringVar(&opts.verifySkips, "verify-skips", "", "before skipping a manifest entry, check the source still matches its recorded size (or hash); re-copy edited sources under a versioned name")
+	flag.IntVar(&opts.minRating, "min-rating", 0, "only copy media rated at least N stars in XMP (unrated files are skipped)")
+	flag.StringVar(&opts.copyAhead, "copy-ahead", "", "stage reads into a bounded queue of this size (e.g. 64M) so slow-source reads overlap destination writes")
+	flag.BoolVar(&opts.snapshot, "snapshot", false, "record source path/size/mtime each run and warn when source files disappear or change between runs")
+	flag.IntVar(&opts.maxDepth, "max-depth", 0, "descend at most N directory levels below each source root (0 = no limit)")
+	flag.BoolVar(&opts.autoThrottle, "auto-throttle", false, "drop to one worker while on battery or under heavy load, restoring -workers when calm (linux only)")
+	flag.Var(&opts.excludeDirs, "exclude-dir", "skip directories with this exact name anywhere in the walk (repeatable)")
+	flag.Var(&opts.spill, "spill", "additional destination root used when the previous one fills up (repeatable)")
+	flag.StringVar(&opts.spillPolicy, "spill-policy", "fill-first", "how files spread across -spill roots: fill-first or by-year (years stay on the root that holds them)")
+	flag.StringVar(&opts.keyword, "keyword", "", "only copy media carrying this XMP/IPTC keyword")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: copysort [flags] SRC [SRC...] DEST\n")
+		fmt.Fprintf(os.Stderr, "       copysort extract FILE...\n")
+		fmt.Fprintf(os.Stderr, "       copysort report DEST\n")
+		fmt.Fprintf(os.Stderr, "       copysort find DEST NAME...\n")
+		fmt.Fprintf(os.Stderr, "       copysort doctor DEST\n")
+		fmt.Fprintf(os.Stderr, "       copysort manifest export|import DEST FILE\n")
+		fmt.Fprintf(os.Stderr, "       copysort manifest merge DEST OTHER\n")
+		flag.PrintDefaults()
+	}
+	if len(os.Args) > 1 && os.Args[1] == "extract" {
+		runExtract(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		runReport(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "find" {
+		runFind(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		runDoctor(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "manifest" {
+		runManifest(os.Args[2:])
+		return
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := initLog(opts.logFile); err != nil {
+		fatalf("%v", err)
+	}
+	defer closeLog()
+	if opts.followSymlinks && opts.preserveSymlinks {
+		fatalf("-follow-symlinks and -preserve-symlinks are mutually exclusive")
+	}
+	if err := checkNormalizeFlag(opts.normalize); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initBuffers(opts.copyBuffer); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initCopyAhead(opts.copyAhead); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initSizeFilters(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := loadMonthNames(opts.monthNames); err != nil {
+		fatalf("%v", err)
+	}
+	if err := applyGranularity(); err != nil {
+		fatalf("%v", err)
+	}
+	if opts.mtime != "source" && opts.mtime != "capture" {
+		fatalf("invalid -mtime %q (want source or capture)", opts.mtime)
+	}
+	if opts.manifestKey != "path" && opts.manifestKey != "hash" {
+		fatalf("invalid -manifest-key %q (want path or hash)", opts.manifestKey)
+	}
+	if opts.verifySkips != "" && opts.verifySkips != "size" && opts.verifySkips != "hash" {
+		fatalf("invalid -verify-skips %q (want size or hash)", opts.verifySkips)
+	}
+	if opts.stripGPS && opts.encrypt != "" {
+		fatalf("-strip-gps cannot edit encrypted copies")
+	}
+	if opts.xattrSums && !xattrSumsOK {
+		fatalf("-xattr-sums is not supported on this platform")
+	}
+	if opts.autoWorkers && opts.autoThrottle {
+		fatalf("-auto-workers and -auto-throttle are mutually exclusive")
+	}
+	if opts.sample > 0 && opts.mirror {
+		fatalf("-sample and -mirror are mutually exclusive (pruning against a sample would delete the rest)")
+	}
+	if opts.iouring && !uringAvailable {
+		fatalf("-iouring is only supported on linux")
+	}
+	if err := initVerifySample(opts.verifySample); err != nil {
+		fatalf("%v", err)
+	}
+	if opts.autoWorkers && (opts.minWorkers < 1 || opts.minWorkers > opts.workers) {
+		fatalf("invalid -min-workers %d (want 1..%d)", opts.minWorkers, opts.workers)
+	}
+	if opts.odirect && !directIOSupported {
+		fatalf("-odirect is only supported on linux")
+	}
+	if err := initEncrypt(opts.encrypt); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initCompress(opts.compress); err != nil {
+		fatalf("%v", err)
+	}
+	if err := loadCameraOffsets(opts.cameraOffsets); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initThumbs(opts.videoThumbs); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initConvert(opts.convert); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initHook(opts.hook); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initUpload(opts.upload); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initSkipReport(opts.skipReport); err != nil {
+		fatalf("%v", err)
+	}
+	if err := loadRoutes(opts.routes); err != nil {
+		fatalf("%v", err)
+	}
+	srcRoots := flag.Args()[:flag.NArg()-1]
+	destRoot := flag.Arg(flag.NArg() - 1)
+	if opts.vss {
+		mapped, cleanup, err := vssSetup(srcRoots)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		atExit = append(atExit, cleanup)
+		defer runAtExit()
+		srcRoots = mapped
+	}
+
+	if err := os.MkdirAll(destRoot, 0o755); err != nil {
+		fatalf("cannot create destination: %v", err)
+	}
+	if err := checkOverlap(srcRoots, destRoot); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initDestPool(destRoot, opts.spill, opts.spillPolicy); err != nil {
+		fatalf("%v", err)
+	}
+	for _, r := range opts.spill {
+		if err := checkOverlap(srcRoots, r); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	if opts.assertReadonly {
+		if err := assertReadonly(srcRoots, destRoot); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	if err := checkArchiveMarker(destRoot); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initNetDest(destRoot); err != nil {
+		fatalf("%v", err)
+	}
+	manifest, err := loadManifest(destRoot)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := initSums(destRoot); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initExifIndex(destRoot); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initHashCache(destRoot); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initExifCache(destRoot); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initAlbums(opts.albums, destRoot); err != nil {
+		fatalf("%v", err)
+	}
+	if err := initTar(opts.tarMode, destRoot); err != nil {
+		fatalf("%v", err)
+	}
+
+	if opts.pprofAddr != "" {
+		startPprof(opts.pprofAddr)
+	}
+	walkStart := time.Now()
+	var jobs []job
+	resumed := false
+	if opts.resume {
+		jobs, resumed = loadResume(srcRoots, destRoot)
+		if resumed {
+			infof("resume: continuing %d jobs from the saved plan\n", len(jobs))
+		}
+	}
+	if !resumed {
+		var err error
+		jobs, err = scanSources(srcRoots)
+		if err != nil {
+			fatalf("%v", err)
+		}
+	}
+	if opts.bench {
+		runBench(jobs, time.Since(walkStart))
+		return
+	}
+	if opts.snapshot {
+		checkSnapshot(jobs, srcRoots, destRoot)
+	}
+	// Captured before sampling and precheck trim the list: mirror
+	// pruning must see everything that is supposed to exist, not just
+	// what this run will copy.
+	allJobs := jobs
+	if opts.sample > 0 && !resumed {
+		jobs = sampleJobs(jobs, opts.sample, manifest)
+	}
+	if !resumed {
+		if err := orderJobs(jobs); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	if opts.plan {
+		planRun(jobs, destRoot, manifest)
+		runAtExit()
+		return
+	}
+	resumeJobs := jobs // what -resume persists: everything precheck might trim
+	if opts.precheck {
+		jobs = precheckJobs(jobs, destRoot, manifest)
+	}
+	if !confirmRun(jobs, destRoot) {
+		fmt.Println("copysort: aborted")
+		runAtExit()
+		os.Exit(1)
+	}
+
+	if opts.resume && !resumed && !opts.dryRun {
+		if err := writeResume(resumeJobs, srcRoots, destRoot); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	st := &stats{}
+	if err := initRunLog(st); err != nil {
+		fatalf("%v", err)
+	}
+	start := time.Now()
+	prog := newProgress(len(jobs))
+	if err := initProgSock(opts.progressSocket, len(jobs)); err != nil {
+		fatalf("progress socket: %v", err)
+	}
+	if err := initMqtt(opts.mqtt, opts.mqttTopic, len(jobs)); err != nil {
+		fatalf("%v", err)
+	}
+	if opts.tui {
+		startTUI(len(jobs))
+	}
+	startPauseControl(prog, st)
+
+	// Metadata extraction (CPU plus a small read at the head of each
+	// file) runs in its own pool so the copy workers never stall on it;
+	// the buffered channel between the stages keeps both busy.
+	if opts.exifWorkers < 1 {
+		opts.exifWorkers = 1
+	}
+	extCh := make(chan *job)
+	copyCh := make(chan *job, 2*opts.workers)
+	var ewg sync.WaitGroup
+	for i := 0; i < opts.exifWorkers; i++ {
+		ewg.Add(1)
+		go func() {
+			defer ewg.Done()
+			for j := range extCh {
+				if j.meta == nil && j.entryName == "" && j.symlink == "" && isMedia(j.relPath) &&
+					!(manifest.has(manifest.key(j)) && manifest.fresh(j)) {
+					j.meta = metaForJob(j)
+					resumeNote(j)
+				}
+				copyCh <- j
+			}
+		}()
+	}
+	go func() {
+		for i := range jobs {
+			extCh <- &jobs[i]
+		}
+		close(extCh)
+		ewg.Wait()
+		close(copyCh)
+	}()
+
+	initThrottle(opts.workers)
+	startAutotune(st)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := range copyCh {
+				gate.wait()
+				throttleAcquire()
+				uiFileStart(id, j.srcPath)
+				t0 := time.Now()
+				processJob(j, destRoot, manifest, st)
+				throttleRelease()
+				st.workTime(id, j.srcRoot, j.size, time.Since(t0))
+				uiFileDone(id, j.size)
+				if !j.queued {
+					prog.tick(j.size)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	runRetries(destRoot, manifest, st, prog)
+	stopTUI()
+	prog.done()
+	runVerifySample(st)
+	closeTars(st)
+	writeSessionTags(st)
+	progSockDone(st, time.Since(start))
+	mqttDone(st, time.Since(start))
+	closeAlbums()
+	saveSpeed(destRoot, st.bytes.Load(), time.Since(start))
+	if opts.mirror {
+		mirrorPrune(allJobs, destRoot, st)
+	}
+	if err := writeSkipReport(); err != nil {
+		errorf("skip report: %v\n", err)
+		st.fail()
+	}
+	logCheckpoint(st, "done")
+	if opts.resume && st.errors.Load() == 0 {
+		clearResume(destRoot)
+	}
+
+	infof("copysort: %d scanned, %d copied, %d skipped, %d errors (%s)\n",
+		len(jobs), st.copied.Load(), st.skipped.Load(), st.errors.Load(), humanBytes(st.bytes.Load()))
+	if !opts.quiet {
+		printBreakdown(st)
+	}
+	reportSlowReads()
+	if opts.phash {
+		reportPhashes()
+	}
+	if st.errors.Load() > 0 {
+		runAtExit()
+		os.Exit(1)
+	}
+}
+
+// Exit codes: 0 every file made it, 1 some files failed (or -strict
+// tripped), 2 the run couldn't start at all. fatalf covers the last case.
+// atExit holds cleanups that must run even on the os.Exit paths, which
+// skip deferred calls — tearing down VSS snapshots, most importantly.
+var atExit []func()
+
+func runAtExit() {
+	for _, f := range atExit {
+		f()
+	}
+	atExit = nil
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "copysort: "+format+"\n", args...)
+	runAtExit()
+	os.Exit(2)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const manifestName = ".copysort-manifest"
+
+// manifest is the append-only record of imported files kept in the
+// destination root. Each line is "key\tdestRelPath" (plus a session tag
+// column when -tag labels the run). The key is the
+// source-relative path by default; with -manifest-key=hash it is the
+// sha256 of the file's content instead, so a photo the phone has moved
+// to a different DCIM folder — or the same shot arriving from a second
+// source — is still recognized as already archived. The two key styles
+// don't mix: switching modes on an existing archive re-copies
+// everything once. Repeat runs skip any file whose key has an entry.
+//
+// Records are two-phase: a "#pending\tkey\tdest" line goes down before
+// the file's bytes do and the plain line after, so a run killed
+// mid-copy never leaves a complete-looking entry for a partial file.
+// Startup reconciles leftovers: a pending with no matching plain line
+// has its destination file (at best partial) deleted and is closed out
+// with a "#void" line, freeing the path for the re-copy.
+
+// manifestEntry is one imported file: where it landed, and — on newer
+// lines — the source size and sha256 it had when archived, written as
+// "size=N" / "sha256=SUM" columns so -verify-skips can tell an
+// unchanged source from an edited one. Older lines carry size -1.
+type manifestEntry struct {
+	dest string
+	size int64
+	sum  string
+}
+
+type manifest struct {
+	mu      sync.Mutex
+	entries map[string]manifestEntry // manifest key -> entry
+	f       *os.File
+}
+
+func loadManifest(destRoot string) (*manifest, error) {
+	p := filepath.Join(destRoot, manifestName)
+	m := &manifest{entries: map[string]manifestEntry{}}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open manifest: %w", err)
+	}
+	pending := map[string]string{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "#pending\t"); ok {
+			src, dest, _ := strings.Cut(rest, "\t")
+			pending[src] = dest
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "#void\t"); ok {
+			src, _, _ := strings.Cut(rest, "\t")
+			delete(pending, src)
+			continue
+		}
+		src, rest, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		delete(pending, src)
+		cols := strings.Split(rest, "\t")
+		e := manifestEntry{dest: cols[0], size: -1}
+		for _, c := range cols[1:] {
+			if v, ok := strings.CutPrefix(c, "size="); ok {
+				if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+					e.size = n
+				}
+			} else if v, ok := strings.CutPrefix(c, "sha256="); ok {
+				e.sum = v
+			}
+			// anything else is the session tag column
+		}
+		m.entries[src] = e
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	m.f = f
+	m.reconcile(pending, destRoot)
+	return m, nil
+}
+
+// reconcile cleans up after an interrupted run: every pending record
+// with no completion line covers a destination file that may hold only
+// part of its source, so the file is removed and the record voided.
+// Dry runs only report, since voiding mutates the manifest.
+func (m *manifest) reconcile(pending map[string]string, destRoot string) {
+	for src, dest := range pending {
+		p := filepath.Join(destRoot, filepath.FromSlash(dest))
+		if filepath.IsAbs(filepath.FromSlash(dest)) {
+			p = filepath.FromSlash(dest) // spilled to another root
+		}
+		if opts.dryRun {
+			warnf("partial %s from interrupted run (would remove)\n", p)
+			continue
+		}
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() {
+			warnf("removing partial %s (interrupted run)\n", p)
+			if err := os.Remove(p); err != nil {
+				warnf("remove %s: %v\n", p, err)
+				continue
+			}
+		}
+		fmt.Fprintf(m.f, "#void\t%s\t%s\n", src, dest)
+	}
+}
+
+// pending marks a destination as claimed-but-unwritten; append with the
+// same key completes it.
+func (m *manifest) pending(srcRel, destRel string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := fmt.Fprintf(m.f, "#pending\t%s\t%s\n", srcRel, destRel)
+	return err
+}
+
+// key returns the manifest key for a job. Symlinks and archive entries
+// always key by path (a link has no content of its own, and hashing the
+// surrounding archive would key every entry identically); hashing
+// failures fall back to the path so the copy is still recorded.
+func (m *manifest) key(j *job) string {
+	if opts.manifestKey != "hash" || j.symlink != "" || j.entryName != "" {
+		return j.relPath
+	}
+	if j.mkey == "" {
+		sum, err := hashSource(j.srcPath, j.size, j.mtime)
+		if err != nil {
+			return j.relPath
+		}
+		j.mkey = sum
+	}
+	return j.mkey
+}
+
+func (m *manifest) has(srcRel string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.entries[srcRel]
+	return ok
+}
+
+// fresh reports whether a manifest entry for this job still matches the
+// source under -verify-skips; false means the source was edited or
+// re-exported since it was archived and deserves a fresh (versioned)
+// copy rather than a silent skip.
+func (m *manifest) fresh(j *job) bool {
+	if opts.verifySkips == "" || j.symlink != "" || j.entryName != "" {
+		return true
+	}
+	m.mu.Lock()
+	e := m.entries[m.key(j)]
+	m.mu.Unlock()
+	if e.size >= 0 && e.size != j.size {
+		return false
+	}
+	if opts.verifySkips == "hash" && e.sum != "" {
+		sum, err := hashFile(j.srcPath)
+		if err == nil && sum != e.sum {
+			return false
+		}
+	}
+	return true
+}
+
+// recordedSum returns the sha256 already recorded for a key, if any, so
+// a corrected record doesn't lose it.
+func (m *manifest) recordedSum(srcRel string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entries[srcRel].sum
+}
+
+// append records a completed copy. size -1 means unknown (symlinks);
+// sum may be empty when the copy path didn't hash.
+func (m *manifest) append(srcRel, destRel string, size int64, sum string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	line := srcRel + "\t" + destRel
+	if size >= 0 {
+		line += fmt.Sprintf("\tsize=%d", size)
+	}
+	if sum != "" {
+		line += "\tsha256=" + sum
+	}
+	if opts.tag != "" {
+		line += "\t" + opts.tag
+	}
+	if _, err := fmt.Fprintln(m.f, line); err != nil {
+		return err
+	}
+	m.entries[srcRel] = manifestEntry{dest: destRel, size: size, sum: sum}
+	return nil
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// `copysort manifest export DEST FILE` writes the manifest's current
+// entries (key, destination, size, sha256, session tag) to FILE as JSON
+// or CSV, picked by extension; `copysort manifest import DEST FILE`
+// appends entries from such a file, skipping keys the manifest already
+// has. Together they are the migration path from other tools — an rsync
+// log or exiftool CSV massaged into the right columns seeds a manifest
+// so an existing archive isn't re-copied — and carry manifests between
+// machines.
+
+// manifestRecord is one manifest entry with its metadata, in the shape
+// export and import files use.
+type manifestRecord struct {
+	Key    string `json:"key"`
+	Dest   string `json:"dest"`
+	Size   int64  `json:"size"` // -1 = unknown
+	Sha256 string `json:"sha256,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+}
+
+func runManifest(args []string) {
+	if len(args) >= 1 {
+		switch args[0] {
+		case "export":
+			runManifestExport(args[1:])
+			return
+		case "import":
+			runManifestImport(args[1:])
+			return
+		case "merge":
+			runManifestMerge(args[1:])
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, "usage: copysort manifest export|import DEST FILE")
+	fmt.Fprintln(os.Stderr, "       copysort manifest merge DEST OTHER")
+	os.Exit(2)
+}
+
+// runManifestMerge folds another machine's manifest into DEST's, so a
+// laptop and a desktop that both import to the same NAS can each resume
+// correctly regardless of which one ran last. OTHER is a destination
+// root or a manifest file. Keys new to DEST are appended; keys present
+// in both with different hashes are conflicts — two different files
+// claimed the same identity — reported and left as DEST has them.
+func runManifestMerge(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: copysort manifest merge DEST OTHER")
+		os.Exit(2)
+	}
+	root, other := args[0], args[1]
+	otherPath := other
+	if fi, err := os.Stat(other); err == nil && fi.IsDir() {
+		otherPath = filepath.Join(other, manifestName)
+	}
+	theirs, err := readManifestFile(otherPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	ours, err := readManifestRecords(root)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	mine := map[string]manifestRecord{}
+	for _, r := range ours {
+		mine[r.Key] = r
+	}
+	f, err := os.OpenFile(filepath.Join(root, manifestName),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	added, same, conflicts := 0, 0, 0
+	for _, r := range theirs {
+		o, ok := mine[r.Key]
+		switch {
+		case !ok:
+			if _, err := fmt.Fprintln(f, formatManifestLine(r)); err != nil {
+				fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+				os.Exit(1)
+			}
+			mine[r.Key] = r
+			added++
+		case o.Sha256 != "" && r.Sha256 != "" && o.Sha256 != r.Sha256:
+			fmt.Fprintf(os.Stderr, "conflict: %s is sha256 %.12s… here but %.12s… in %s — keeping ours\n",
+				r.Key, o.Sha256, r.Sha256, other)
+			conflicts++
+		default:
+			same++
+		}
+	}
+	f.Close()
+	fmt.Printf("merged %d new entries from %s (%d already present, %d conflicts)\n",
+		added, other, same, conflicts)
+	if conflicts > 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func runManifestExport(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: copysort manifest export DEST FILE(.json|.csv)")
+		os.Exit(2)
+	}
+	root, out := args[0], args[1]
+	recs, err := readManifestRecords(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	sort.Slice(recs, func(a, b int) bool { return recs[a].Key < recs[b].Key })
+	if err := writeManifestRecords(out, recs); err != nil {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("exported %d entries to %s\n", len(recs), out)
+	os.Exit(0)
+}
+
+func runManifestImport(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: copysort manifest import DEST FILE(.json|.csv)")
+		os.Exit(2)
+	}
+	root, in := args[0], args[1]
+	recs, err := readRecordsFile(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	existing, err := readManifestRecords(root)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	have := map[string]bool{}
+	for _, r := range existing {
+		have[r.Key] = true
+	}
+	f, err := os.OpenFile(filepath.Join(root, manifestName),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	added, skipped := 0, 0
+	for _, r := range recs {
+		if r.Key == "" || r.Dest == "" || have[r.Key] {
+			skipped++
+			continue
+		}
+		if _, err := fmt.Fprintln(f, formatManifestLine(r)); err != nil {
+			fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+			os.Exit(1)
+		}
+		have[r.Key] = true
+		added++
+	}
+	f.Close()
+	fmt.Printf("imported %d entries (%d already present or invalid)\n", added, skipped)
+	os.Exit(0)
+}
+
+// readManifestRecords reads a destination's manifest without taking it
+// over the way loadManifest does — no reconciliation, no open handle.
+// The last line for a key wins, matching the loader.
+func readManifestRecords(root string) ([]manifestRecord, error) {
+	return readManifestFile(filepath.Join(root, manifestName))
+}
+
+func readManifestFile(path string) ([]manifestRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var recs []manifestRecord
+	index := map[string]int{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, rest, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		cols := strings.Split(rest, "\t")
+		r := manifestRecord{Key: key, Dest: cols[0], Size: -1}
+		for _, c := range cols[1:] {
+			if v, ok := strings.CutPrefix(c, "size="); ok {
+				if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+					r.Size = n
+				}
+			} else if v, ok := strings.CutPrefix(c, "sha256="); ok {
+				r.Sha256 = v
+			} else {
+				r.Tag = c
+			}
+		}
+		if i, ok := index[key]; ok {
+			recs[i] = r
+		} else {
+			index[key] = len(recs)
+			recs = append(recs, r)
+		}
+	}
+	return recs, sc.Err()
+}
+
+// formatManifestLine renders a record as a completed manifest line, the
+// same shape manifest.append writes.
+func formatManifestLine(r manifestRecord) string {
+	line := r.Key + "\t" + r.Dest
+	if r.Size >= 0 {
+		line += fmt.Sprintf("\tsize=%d", r.Size)
+	}
+	if r.Sha256 != "" {
+		line += "\tsha256=" + r.Sha256
+	}
+	if r.Tag != "" {
+		line += "\t" + r.Tag
+	}
+	return line
+}
+
+func writeManifestRecords(path string, recs []manifestRecord) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		b, err := json.MarshalIndent(recs, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, append(b, '\n'), 0o644)
+	case ".csv":
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		w := csv.NewWriter(f)
+		w.Write([]string{"key", "dest", "size", "sha256", "tag"})
+		for _, r := range recs {
+			size := ""
+			if r.Size >= 0 {
+				size = strconv.FormatInt(r.Size, 10)
+			}
+			w.Write([]string{r.Key, r.Dest, size, r.Sha256, r.Tag})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return fmt.Errorf("%s: want a .json or .csv file", path)
+}
+
+func readRecordsFile(path string) ([]manifestRecord, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var recs []manifestRecord
+		if err := json.Unmarshal(b, &recs); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return recs, nil
+	case ".csv":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		cr := csv.NewReader(f)
+		cr.FieldsPerRecord = -1 // tolerate files without the tag column
+		rows, err := cr.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		var recs []manifestRecord
+		for i, row := range rows {
+			if len(row) < 2 || (i == 0 && row[0] == "key") {
+				continue
+			}
+			r := manifestRecord{Key: row[0], Dest: row[1], Size: -1}
+			if len(row) > 2 && row[2] != "" {
+				if n, err := strconv.ParseInt(row[2], 10, 64); err == nil {
+					r.Size = n
+				}
+			}
+			if len(row) > 3 {
+				r.Sha256 = row[3]
+			}
+			if len(row) > 4 {
+				r.Tag = row[4]
+			}
+			recs = append(recs, r)
+		}
+		return recs, nil
+	}
+	return nil, fmt.Errorf("%s: want a .json or .csv file", path)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Wrong-drive protection: -archive-id stamps the destination root with
+// a .copysort-archive marker on the first import and refuses later runs
+// when the marker is missing or carries a different ID — the classic
+// failure being a different external drive (or none at all) mounted at
+// the path the archive usually lives on. -force overrides a refusal,
+// adopting the given ID.
+
+const archiveMarkerName = ".copysort-archive"
+
+func checkArchiveMarker(destRoot string) error {
+	if opts.archiveID == "" {
+		return nil
+	}
+	p := filepath.Join(destRoot, archiveMarkerName)
+	b, err := os.ReadFile(p)
+	switch {
+	case err == nil:
+		id := strings.TrimSpace(string(b))
+		if id == opts.archiveID {
+			return nil
+		}
+		if !opts.force {
+			return fmt.Errorf("destination %s belongs to archive %q, not %q — wrong drive? (-force to override)", destRoot, id, opts.archiveID)
+		}
+		warnf("archive marker says %q, continuing under -force\n", id)
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		// A fresh destination (no manifest yet) gets stamped; an
+		// established archive with no marker looks like the wrong
+		// drive mounted at the right path.
+		if _, merr := os.Stat(filepath.Join(destRoot, manifestName)); merr == nil && !opts.force {
+			return fmt.Errorf("destination %s has a manifest but no %s marker — wrong drive? (-force to adopt it)", destRoot, archiveMarkerName)
+		}
+		if opts.dryRun {
+			infof("would stamp %s as archive %q\n", destRoot, opts.archiveID)
+			return nil
+		}
+		if err := os.WriteFile(p, []byte(opts.archiveID+"\n"), 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", p, err)
+		}
+		infof("stamped %s as archive %q\n", destRoot, opts.archiveID)
+		return nil
+	default:
+		return fmt.Errorf("read %s: %w", p, err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Mirror mode: -mirror turns the unsorted relative-path tree into an
+// rsync-like incremental backup by deleting destination files whose
+// source counterpart has been removed. The date-sorted media tree is an
+// archive and is never pruned. -dry-run reports what would go; -trash
+// moves victims into .copysort-trash under the destination instead of
+// unlinking them.
+
+const trashDirName = ".copysort-trash"
+
+func mirrorPrune(jobs []job, destRoot string, st *stats) {
+	expected := map[string]bool{}
+	for i := range jobs {
+		all := append([]*job{&jobs[i]}, jobs[i].companions...)
+		for _, j := range all {
+			if isMedia(j.relPath) {
+				continue
+			}
+			rel := normalizeName(j.relPath) + compression.suffix + encrypt.suffix
+			expected[rel] = true
+		}
+	}
+	unsortedRoot := filepath.Join(destRoot, "unsorted")
+	pruned := 0
+	filepath.WalkDir(unsortedRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(unsortedRoot, p)
+		if err != nil {
+			return nil
+		}
+		if expected[filepath.ToSlash(rel)] {
+			return nil
+		}
+		if opts.dryRun {
+			infof("would prune %s\n", p)
+			pruned++
+			return nil
+		}
+		if opts.trash {
+			dest := filepath.Join(destRoot, trashDirName, "unsorted", rel)
+			if err := os.MkdirAll(filepath.Dir(dest), 0o755); err == nil {
+				err = os.Rename(p, dest)
+			}
+			if err != nil {
+				errorf("prune %s: %v\n", p, err)
+				st.fail()
+				return nil
+			}
+		} else if err := os.Remove(p); err != nil {
+			errorf("prune %s: %v\n", p, err)
+			st.fail()
+			return nil
+		}
+		if opts.verbose {
+			infof("prune %s\n", p)
+		}
+		pruned++
+		return nil
+	})
+	if pruned > 0 {
+		verb := "pruned"
+		if opts.dryRun {
+			verb = "would prune"
+		} else if opts.trash {
+			verb = "trashed"
+		}
+		infof("copysort: %s %d files from unsorted tree\n", verb, pruned)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTT publishing: -mqtt tcp://broker:1883 mirrors the progress-socket
+// events onto an MQTT broker, so Home Assistant and friends can show an
+// import on a wall dashboard and alert when an SD card finishes
+// offloading. The protocol subset needed — CONNECT, QoS 0 PUBLISH,
+// DISCONNECT over plain TCP — is small enough to speak directly rather
+// than take on a client dependency. Messages are published retained
+// under -mqtt-topic (TOPIC/start, TOPIC/progress, TOPIC/done) with the
+// same JSON payloads the progress socket streams; progress messages are
+// thinned to one every couple of seconds, which is dashboard cadence,
+// not terminal cadence. A broker that drops mid-run costs a warning and
+// the rest of the events, never the copy.
+
+const mqttInterval = 2 * time.Second
+
+var mqtt struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	topic  string
+	last   time.Time
+	warned bool
+}
+
+// mqttString encodes a length-prefixed MQTT string.
+func mqttString(s string) []byte {
+	b := []byte{byte(len(s) >> 8), byte(len(s))}
+	return append(b, s...)
+}
+
+// mqttPacket wraps a packet body in the fixed header: type/flags byte
+// plus the remaining length as a varint.
+func mqttPacket(typ byte, body []byte) []byte {
+	p := []byte{typ}
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		p = append(p, d)
+		if n == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+func initMqtt(addr, topic string, total int) error {
+	if addr == "" {
+		return nil
+	}
+	addr = strings.TrimPrefix(addr, "tcp://")
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	// CONNECT: protocol "MQTT" level 4, clean session, no keepalive so
+	// a long quiet stretch needs no PINGREQ machinery.
+	body := append(mqttString("MQTT"), 4, 0x02, 0, 0)
+	body = append(body, mqttString(fmt.Sprintf("copysort-%d", os.Getpid()))...)
+	if _, err := conn.Write(mqttPacket(0x10, body)); err != nil {
+		conn.Close()
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	ack := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		conn.Close()
+		return fmt.Errorf("mqtt: read CONNACK: %w", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	if ack[0] != 0x20 || ack[3] != 0 {
+		conn.Close()
+		return fmt.Errorf("mqtt: broker refused connection (code %d)", ack[3])
+	}
+	mqtt.conn = conn
+	mqtt.topic = topic
+	mqttEmit(progEvent{Event: "start", Total: total})
+	return nil
+}
+
+func mqttEmit(ev progEvent) {
+	mqtt.mu.Lock()
+	defer mqtt.mu.Unlock()
+	if mqtt.conn == nil {
+		return
+	}
+	if ev.Event == "progress" && time.Since(mqtt.last) < mqttInterval {
+		return
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	// 0x31 = PUBLISH, QoS 0, retained, so a dashboard that reconnects
+	// mid-run still sees the latest state.
+	body := append(mqttString(mqtt.topic+"/"+ev.Event), payload...)
+	if _, err := mqtt.conn.Write(mqttPacket(0x31, body)); err != nil {
+		if !mqtt.warned {
+			warnf("mqtt: %v — progress publishing stopped\n", err)
+			mqtt.warned = true
+		}
+		mqtt.conn.Close()
+		mqtt.conn = nil
+		return
+	}
+	mqtt.last = time.Now()
+}
+
+// mqttDone publishes the final counts and disconnects cleanly.
+func mqttDone(st *stats, elapsed time.Duration) {
+	mqttEmit(progEvent{
+		Event:   "done",
+		Copied:  st.copied.Load(),
+		Skipped: st.skipped.Load(),
+		Errors:  st.errors.Load(),
+		Bytes:   st.bytes.Load(),
+		Elapsed: elapsed.Seconds(),
+	})
+	mqtt.mu.Lock()
+	defer mqtt.mu.Unlock()
+	if mqtt.conn != nil {
+		mqtt.conn.Write(mqttPacket(0xe0, nil)) // DISCONNECT
+		mqtt.conn.Close()
+		mqtt.conn = nil
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMqttString(t *testing.T) {
+	if got := mqttString("ab"); !bytes.Equal(got, []byte{0, 2, 'a', 'b'}) {
+		t.Errorf("mqttString(\"ab\") = %v", got)
+	}
+	if got := mqttString(""); !bytes.Equal(got, []byte{0, 0}) {
+		t.Errorf("mqttString(\"\") = %v", got)
+	}
+}
+
+func TestMqttPacketRemainingLength(t *testing.T) {
+	// The remaining-length varint: 7 bits per byte, continuation in the
+	// top bit. 321 = 0xC1 0x02 is the spec's own worked example.
+	tests := []struct {
+		bodyLen int
+		header  []byte
+	}{
+		{0, []byte{0xe0, 0x00}},
+		{127, []byte{0x30, 0x7f}},
+		{128, []byte{0x30, 0x80, 0x01}},
+		{321, []byte{0x30, 0xc1, 0x02}},
+		{16384, []byte{0x30, 0x80, 0x80, 0x01}},
+	}
+	for _, tc := range tests {
+		typ := tc.header[0]
+		p := mqttPacket(typ, make([]byte, tc.bodyLen))
+		if !bytes.Equal(p[:len(tc.header)], tc.header) {
+			t.Errorf("mqttPacket(%#x, %d bytes) header = %v, want %v",
+				typ, tc.bodyLen, p[:len(tc.header)], tc.header)
+		}
+		if len(p) != len(tc.header)+tc.bodyLen {
+			t.Errorf("mqttPacket(%#x, %d bytes) total length = %d", typ, tc.bodyLen, len(p))
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Network destinations fail differently from unplugged drives: when an
+// SMB or NFS share drops, the mountpoint left behind is a perfectly
+// writable local directory, so nothing errors — the run just pours
+// gigabytes onto the wrong disk. When the destination sits on a network
+// filesystem, copysort records the mount's identity at startup,
+// write-probes it to catch an already-stale share before any copying,
+// and a watchdog re-checks the identity every few seconds; if it
+// changes, the run pauses until the share is mounted again. How a mount
+// is identified is platform-specific (statfs type and fsid on linux;
+// elsewhere the checks are off).
+
+const netCheckInterval = 5 * time.Second
+
+const netProbeName = ".copysort-probe"
+
+var netDest struct {
+	root  string // "" when the destination is local or undetectable
+	ident fsIdent
+	stale atomic.Bool
+}
+
+func initNetDest(destRoot string) error {
+	name, ident, err := netFsIdent(destRoot)
+	if err != nil || name == "" {
+		return nil
+	}
+	probe := filepath.Join(destRoot, netProbeName)
+	if err := os.WriteFile(probe, nil, 0o644); err != nil {
+		return fmt.Errorf("network destination %s is not writable (stale mount?): %w", destRoot, err)
+	}
+	os.Remove(probe)
+	netDest.root, netDest.ident = destRoot, ident
+	infof("destination %s is on %s, stale-mount watchdog enabled\n", destRoot, name)
+	go watchNetDest()
+	return nil
+}
+
+// destStale reports whether the destination's filesystem is no longer
+// the one the run started on — the share dropped and writes would land
+// in the bare mountpoint.
+func destStale(root string) bool {
+	if netDest.root == "" || root != netDest.root {
+		return false
+	}
+	_, ident, err := netFsIdent(root)
+	return err != nil || ident != netDest.ident
+}
+
+func watchNetDest() {
+	for range time.Tick(netCheckInterval) {
+		if !destStale(netDest.root) {
+			netDest.stale.Store(false)
+			continue
+		}
+		if netDest.stale.Swap(true) {
+			continue // already being handled
+		}
+		go awaitNetMount()
+	}
+}
+
+// awaitNetMount pauses the run and blocks until the original filesystem
+// is mounted on the destination again, mirroring awaitDest for
+// removable drives.
+func awaitNetMount() {
+	destWait.Lock()
+	defer destWait.Unlock()
+	if !destStale(netDest.root) {
+		return
+	}
+	gate.setPaused(true)
+	fmt.Fprintf(os.Stderr, "\ncopysort: destination %s mount went stale — remount the share to resume\n", netDest.root)
+	for destStale(netDest.root) {
+		time.Sleep(2 * time.Second)
+	}
+	fmt.Fprintln(os.Stderr, "copysort: destination mount is back, resuming")
+	gate.setPaused(false)
+}
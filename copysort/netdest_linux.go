@@ -0,0 +1,32 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+// fsIdent pins down which filesystem instance a path lives on; a
+// remount of the same share gets a fresh fsid and so reads as a
+// different mount, which errs on the safe side.
+type fsIdent struct {
+	typ  int64
+	fsid [2]int32
+}
+
+// statfs f_type magics for the network filesystems worth watching.
+var netFsTypes = map[int64]string{
+	0x6969:     "nfs",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+}
+
+// netFsIdent returns the filesystem name and identity when root is on a
+// network filesystem, and "" when it is local.
+func netFsIdent(root string) (string, fsIdent, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(root, &st); err != nil {
+		return "", fsIdent{}, err
+	}
+	id := fsIdent{typ: int64(st.Type), fsid: st.Fsid.X__val}
+	return netFsTypes[id.typ], id, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+// No statfs here, so network destinations can't be told from local
+// ones; the stale-mount watchdog stays off.
+
+type fsIdent struct{}
+
+func netFsIdent(root string) (string, fsIdent, error) {
+	return "", fsIdent{}, nil
+}
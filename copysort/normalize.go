@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func checkNormalizeFlag(v string) error {
+	switch v {
+	case "", "nfc", "nfd":
+		return nil
+	}
+	return fmt.Errorf("invalid -normalize form %q (want nfc or nfd)", v)
+}
+
+// normalizeName applies the requested unicode normalization form to every
+// element of a destination-relative path. macOS stores filenames in NFD
+// while most other sources produce NFC; without normalization "café.jpg"
+// from a Mac export and its NFC twin from a phone end up as two distinct
+// destination files and two manifest entries.
+func normalizeName(rel string) string {
+	var form norm.Form
+	switch opts.normalize {
+	case "nfc":
+		form = norm.NFC
+	case "nfd":
+		form = norm.NFD
+	default:
+		return rel
+	}
+	parts := strings.Split(rel, "/")
+	for i, p := range parts {
+		parts[i] = form.String(p)
+	}
+	return path.Join(parts...)
+}
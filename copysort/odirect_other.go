@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import (
+	"hash"
+	"io"
+	"os"
+)
+
+// O_DIRECT is linux-only; elsewhere -odirect is rejected at startup.
+
+const directIOSupported = false
+
+const odirectMin = 64 << 20
+
+func copyDirect(in io.Reader, out *os.File, h hash.Hash) error { panic("unreachable") }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Per-camera clock offsets: -camera-offsets FILE corrects bodies whose
+// clocks drifted (or sat in the wrong timezone) so multi-camera shoots
+// interleave correctly in the date folders. Each non-comment line is
+//
+//	<model or serial> = <offset>
+//
+// e.g. "Canon EOS R6 = 3m10s" or "021024001234 = -1h". Serial matches
+// win over model matches.
+
+var cameraOffsets map[string]time.Duration
+
+func loadCameraOffsets(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("camera offsets: %w", err)
+	}
+	defer f.Close()
+	cameraOffsets = map[string]time.Duration{}
+	sc := bufio.NewScanner(f)
+	lineno := 0
+	for sc.Scan() {
+		lineno++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("camera offsets: %s:%d: want \"model = offset\"", path, lineno)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(val))
+		if err != nil {
+			return fmt.Errorf("camera offsets: %s:%d: %v", path, lineno, err)
+		}
+		cameraOffsets[strings.TrimSpace(key)] = d
+	}
+	return sc.Err()
+}
+
+// applyCameraOffset corrects an EXIF timestamp by the configured offset
+// for the camera that took it.
+func applyCameraOffset(x exifInfo) time.Time {
+	if len(cameraOffsets) == 0 {
+		return x.time
+	}
+	if d, ok := cameraOffsets[x.serial]; ok && x.serial != "" {
+		return x.time.Add(d)
+	}
+	if d, ok := cameraOffsets[x.model]; ok && x.model != "" {
+		return x.time.Add(d)
+	}
+	return x.time
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Pause/resume for plain (non-TUI) runs: sending SIGUSR1 toggles the
+// worker pause gate, and typing "p"/"r" (plus Enter) on the terminal does
+// the same. Workers finish their current file and then block, giving the
+// disk bandwidth back for a while without killing a multi-hour import.
+
+func startPauseControl(prog *progress, st *stats) {
+	if opts.tui {
+		return // the TUI has its own keybindings
+	}
+	notifyPauseSignal(func() { togglePause(prog, st) })
+	go func() {
+		sc := bufio.NewScanner(os.Stdin)
+		for sc.Scan() {
+			switch strings.TrimSpace(sc.Text()) {
+			case "p":
+				if !gatePaused() {
+					togglePause(prog, st)
+				}
+			case "r":
+				if gatePaused() {
+					togglePause(prog, st)
+				}
+			}
+		}
+	}()
+}
+
+func gatePaused() bool {
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+	return gate.paused
+}
+
+func togglePause(prog *progress, st *stats) {
+	if gatePaused() {
+		gate.setPaused(false)
+		fmt.Println("\ncopysort: resumed")
+		return
+	}
+	gate.setPaused(true)
+	prog.mu.Lock()
+	count, total, bytes, started := prog.count, prog.total, prog.bytes, prog.started
+	prog.mu.Unlock()
+	rate := float64(bytes) / time.Since(started).Seconds()
+	fmt.Printf("\ncopysort: paused after current files — %d/%d done, %s copied, %s/s average, %d errors (p/r or SIGUSR1 to resume)\n",
+		count, total, humanBytes(st.bytes.Load()), humanBytes(int64(rate)), st.errors.Load())
+}
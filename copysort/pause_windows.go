@@ -0,0 +1,7 @@
+//go:build windows
+
+package main
+
+// notifyPauseSignal is a no-op on Windows, which has no SIGUSR1; the
+// keyboard control in startPauseControl still works.
+func notifyPauseSignal(toggle func()) {}
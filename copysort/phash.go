@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Perceptual near-duplicate detection: -phash computes a dHash for every
+// decodable photo as it is copied and reports visually identical pairs
+// (re-encodes, resizes, WhatsApp-recompressed copies) at the end of the
+// run. This is separate from exact dedupe — the interesting duplicates
+// here have different bytes.
+
+// phashMaxDistance is the Hamming distance under which two dHashes are
+// considered the same picture.
+const phashMaxDistance = 6
+
+var phashes struct {
+	mu      sync.Mutex
+	entries []phashEntry
+}
+
+type phashEntry struct {
+	hash uint64
+	path string // destination path
+}
+
+// dHash decodes an image, shrinks it to 9x8 grayscale by box sampling,
+// and sets one bit per adjacent-pixel brightness comparison.
+func dHash(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, err
+	}
+	b := img.Bounds()
+	const w, h = 9, 8
+	var gray [h][w]uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Sample the center of each cell; full box averaging
+			// buys little for duplicate detection.
+			sx := b.Min.X + (2*x+1)*b.Dx()/(2*w)
+			sy := b.Min.Y + (2*y+1)*b.Dy()/(2*h)
+			r, g, bl, _ := img.At(sx, sy).RGBA()
+			gray[y][x] = (299*uint64(r) + 587*uint64(g) + 114*uint64(bl)) / 1000
+		}
+	}
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+func recordPhash(destPath string) {
+	h, err := dHash(destPath)
+	if err != nil {
+		return // not decodable (RAW, HEIC, corrupt) — skip silently
+	}
+	phashes.mu.Lock()
+	phashes.entries = append(phashes.entries, phashEntry{h, destPath})
+	phashes.mu.Unlock()
+}
+
+// reportPhashes prints clusters of visually identical images.
+func reportPhashes() {
+	phashes.mu.Lock()
+	entries := phashes.entries
+	phashes.mu.Unlock()
+	if len(entries) < 2 {
+		return
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].path < entries[b].path })
+	used := make([]bool, len(entries))
+	groups := 0
+	for i := range entries {
+		if used[i] {
+			continue
+		}
+		var group []string
+		for k := i + 1; k < len(entries); k++ {
+			if !used[k] && bits.OnesCount64(entries[i].hash^entries[k].hash) <= phashMaxDistance {
+				group = append(group, entries[k].path)
+				used[k] = true
+			}
+		}
+		if group == nil {
+			continue
+		}
+		if groups == 0 {
+			fmt.Println("visually identical images:")
+		}
+		groups++
+		fmt.Printf("  %s\n", entries[i].path)
+		for _, p := range group {
+			fmt.Printf("    ~ %s\n", p)
+		}
+	}
+	if groups > 0 {
+		fmt.Printf("copysort: %d near-duplicate groups found\n", groups)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Photos.app libraries: pointing copysort at a .photoslibrary bundle
+// used to walk its UUID-named internals blindly, thumbnails and all.
+// The adapter scans only the originals tree (Masters/ in older
+// libraries), lets the usual EXIF extraction supply true capture dates,
+// and — when the sqlite3 tool is on PATH, the same way ffmpeg and
+// heif-convert are borrowed — reads user album titles out of the
+// library's Photos.sqlite so -albums reflects curation instead of hex
+// shard directories.
+
+// photosAlbums maps an original's lowercased filename to its album
+// title ("" for assets in no album, which also suppresses the shard
+// directories from ever being mistaken for album names).
+var photosAlbums map[string]string
+
+// isPhotosLibrary reports whether a source root is a Photos.app bundle.
+func isPhotosLibrary(root string) bool {
+	if !strings.EqualFold(filepath.Ext(root), ".photoslibrary") {
+		return false
+	}
+	fi, err := os.Stat(root)
+	return err == nil && fi.IsDir()
+}
+
+// scanPhotosLibrary walks a library's originals into the job list.
+func scanPhotosLibrary(root string, jobs *[]job) error {
+	originals := filepath.Join(root, "originals")
+	if _, err := os.Stat(originals); err != nil {
+		originals = filepath.Join(root, "Masters")
+		if _, err := os.Stat(originals); err != nil {
+			return err
+		}
+	}
+	if albums.mode != "" {
+		loadPhotosAlbums(filepath.Join(root, "database", "Photos.sqlite"))
+	}
+	before := len(*jobs)
+	w := &walker{root: originals, jobs: jobs}
+	if err := w.walk(originals); err != nil {
+		return err
+	}
+	// Even when the database gave us nothing, claim every original so
+	// the shard directories never leak out as album names.
+	if albums.mode != "" {
+		for _, j := range (*jobs)[before:] {
+			name := strings.ToLower(path.Base(j.relPath))
+			if _, ok := photosAlbums[name]; !ok {
+				photosAlbums[name] = ""
+			}
+		}
+	}
+	return nil
+}
+
+// loadPhotosAlbums pulls album membership out of Photos.sqlite via the
+// sqlite3 CLI. The album/asset join table is named per schema version,
+// so it is discovered first. Any failure just means no album names —
+// the import itself doesn't depend on the database.
+func loadPhotosAlbums(db string) {
+	query := func(q string) ([]string, error) {
+		out, err := exec.Command("sqlite3", "-readonly", "-noheader", db, q).Output()
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(strings.TrimRight(string(out), "\n"), "\n"), nil
+	}
+	photosAlbums = map[string]string{}
+	if names, err := query(`SELECT ZFILENAME FROM ZASSET`); err == nil {
+		for _, n := range names {
+			photosAlbums[strings.ToLower(n)] = ""
+		}
+	} else {
+		debugf("photos library: %s: %v\n", db, err)
+		return
+	}
+	tables, err := query(`SELECT name FROM sqlite_master WHERE type='table' AND name GLOB 'Z_*ASSETS'`)
+	if err != nil || len(tables) == 0 || tables[0] == "" {
+		return
+	}
+	jt := tables[0]
+	cols, err := query(`SELECT name FROM pragma_table_info('` + jt + `')`)
+	if err != nil {
+		return
+	}
+	var albumCol, assetCol string
+	for _, c := range cols {
+		switch {
+		case strings.HasSuffix(c, "ALBUMS") && albumCol == "":
+			albumCol = c
+		case strings.HasSuffix(c, "ASSETS") && !strings.Contains(c, "FOK") && assetCol == "":
+			assetCol = c
+		}
+	}
+	if albumCol == "" || assetCol == "" {
+		return
+	}
+	// ZKIND 2 is a plain user album.
+	rows, err := query(`SELECT ga.ZTITLE || char(9) || a.ZFILENAME` +
+		` FROM "` + jt + `" jt` +
+		` JOIN ZGENERICALBUM ga ON ga.Z_PK = jt."` + albumCol + `"` +
+		` JOIN ZASSET a ON a.Z_PK = jt."` + assetCol + `"` +
+		` WHERE ga.ZKIND = 2 AND ga.ZTITLE IS NOT NULL`)
+	if err != nil {
+		debugf("photos library: albums: %v\n", err)
+		return
+	}
+	for _, row := range rows {
+		title, file, ok := strings.Cut(row, "\t")
+		if ok && title != "" {
+			photosAlbums[strings.ToLower(file)] = title
+		}
+	}
+}
+
+// photosAlbumFor looks a file up in the library's album map; found
+// reports whether the file came out of a Photos library at all.
+func photosAlbumFor(rel string) (string, bool) {
+	if photosAlbums == nil {
+		return "", false
+	}
+	title, ok := photosAlbums[strings.ToLower(path.Base(rel))]
+	return title, ok
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// -plan applies the destination template to every pending file and
+// reports what the layout would look like before any bytes move:
+// destination collisions (files that would contend for one path, and so
+// pick up _2 suffixes), dates that would fall back to filenames or
+// mtimes, rename variables that would expand empty, and paths long
+// enough to upset other filesystems. Scanning and metadata extraction
+// run exactly as a real run would; nothing is written. The difference
+// from -dry-run is the angle: -dry-run walks the run file by file,
+// -plan aggregates what a template change would do to the whole layout.
+
+// planMaxPath is where destination paths start troubling Windows tools
+// and some network filesystems.
+const planMaxPath = 255
+
+// plannedDest computes the destination-relative path a job would copy
+// to, along with the date source and camera model that fed the
+// template. It mirrors processJob's naming up to the compression and
+// encryption suffixes; nothing is written. Metadata extracted along the
+// way is cached on the job so the extract pool doesn't repeat it.
+func plannedDest(j *job) (destRel, dateSrc, model string) {
+	if !isMedia(j.relPath) {
+		return path.Join("unsorted", normalizeName(j.relPath)), "", ""
+	}
+	var t time.Time
+	var src string
+	if j.meta == nil && j.entryName == "" {
+		j.meta = metaForJob(j)
+	}
+	if j.meta != nil {
+		t, src, model = j.meta.when, j.meta.source, j.meta.model
+	} else { // archive entry; date from its name without spooling
+		t, src, model = extractTimestamp(j.srcPath, path.Base(j.relPath))
+	}
+	name := normalizeName(path.Base(j.relPath))
+	if opts.rename != "" {
+		name = expandRename(opts.rename, t, model, name)
+	}
+	return path.Join(expandTemplate(opts.template, t), j.subdir, name), src, model
+}
+
+func planRun(jobs []job, destRoot string, m *manifest) {
+	counts := map[string]int{}
+	var pending, media, fallbacks, emptyVars, longPaths int
+	var longest string
+	for i := range jobs {
+		j := &jobs[i]
+		if j.symlink != "" {
+			continue
+		}
+		// Manifest exclusion is only cheap with path keys; hash keys
+		// would mean hashing every source just to plan.
+		if opts.manifestKey == "path" && m.has(m.key(j)) && m.fresh(j) {
+			continue
+		}
+		pending++
+		destRel, src, model := plannedDest(j)
+		if isMedia(j.relPath) {
+			media++
+			if src == dateFromFilename || src == dateFromModTime {
+				fallbacks++
+			}
+			if opts.rename != "" && model == "" && strings.Contains(opts.rename, "{camera}") {
+				emptyVars++
+			}
+		}
+		counts[destRel]++
+		if full := filepath.Join(destRoot, filepath.FromSlash(destRel)); len(full) > planMaxPath {
+			longPaths++
+			if len(full) > len(longest) {
+				longest = full
+			}
+		}
+	}
+
+	var colliding, contested int
+	var examples []string
+	for rel, c := range counts {
+		taken := false
+		if _, err := os.Stat(filepath.Join(destRoot, filepath.FromSlash(rel))); err == nil {
+			taken = true
+		}
+		if c > 1 || (c > 0 && taken) {
+			contested++
+			n := c - 1
+			if taken {
+				n = c
+			}
+			colliding += n
+			examples = append(examples, fmt.Sprintf("%s (%d files)", rel, c))
+		}
+	}
+	sort.Strings(examples)
+	if len(examples) > 5 {
+		examples = examples[:5]
+	}
+
+	fmt.Printf("plan: %d pending files (%d media) under %s\n", pending, media, opts.template)
+	fmt.Printf("  collisions:     %d files would contend for %d already-taken or shared paths\n", colliding, contested)
+	for _, e := range examples {
+		fmt.Printf("    %s\n", e)
+	}
+	fmt.Printf("  date fallbacks: %d files would be dated from filename or mtime\n", fallbacks)
+	if opts.rename != "" {
+		fmt.Printf("  empty variables: %d files would expand {camera} empty\n", emptyVars)
+	}
+	fmt.Printf("  long paths:     %d destination paths exceed %d characters\n", longPaths, planMaxPath)
+	if longest != "" {
+		fmt.Printf("    longest: %s (%d)\n", longest, len(longest))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// -precheck stats every computed destination in parallel before the
+// copy starts and reclassifies the job list. Files already recorded in
+// the manifest or already sitting at their destination with matching
+// size and mtime are dropped up front, so the progress total and ETA
+// measure work that will actually happen instead of counting down
+// through thousands of instant skips; paths where something different
+// already lives are reported as conflicts before the run rather than
+// surfacing as _2 suffixes halfway through. Runs that transform bytes
+// (convert, compress, encrypt) can't be size-compared, so their files
+// stay in the copy bucket.
+
+// precheckWorkers bounds the stat pool; destination stats are cheap but
+// a network filesystem rewards having many in flight.
+const precheckWorkers = 16
+
+func precheckJobs(jobs []job, destRoot string, m *manifest) []job {
+	transforming := converter.enabled || compression.suffix != "" || encrypt.suffix != ""
+	keep := make([]bool, len(jobs))
+	var fresh, done, conflicts atomic.Int64
+	idx := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < precheckWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				j := &jobs[i]
+				keep[i] = true
+				if j.symlink != "" {
+					continue
+				}
+				// Manifest exclusion is only cheap with path keys; hash
+				// keys would hash every source just to pre-check.
+				if opts.manifestKey == "path" && m.has(m.key(j)) && m.fresh(j) {
+					keep[i] = false
+					fresh.Add(1)
+					continue
+				}
+				if transforming {
+					continue
+				}
+				rel, _, _ := plannedDest(j)
+				full := filepath.Join(destRoot, filepath.FromSlash(rel))
+				fi, err := os.Stat(full)
+				if err != nil {
+					continue
+				}
+				if fi.Size() == j.size && mtimeMatch(full, fi.ModTime().UnixNano(), j.mtime.UnixNano()) {
+					keep[i] = false
+					done.Add(1)
+				} else {
+					conflicts.Add(1)
+				}
+			}
+		}()
+	}
+	for i := range jobs {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+
+	out := make([]job, 0, len(jobs))
+	for i := range jobs {
+		if keep[i] {
+			out = append(out, jobs[i])
+		}
+	}
+	infof("precheck: %d to copy, %d already in the manifest, %d identical at destination, %d name conflicts\n",
+		len(out), fresh.Load(), done.Load(), conflicts.Load())
+	return out
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// progress is the single-line terminal progress bar shared by all workers.
+type progress struct {
+	mu        sync.Mutex
+	total     int
+	count     int
+	bytes     int64
+	started   time.Time
+	last      time.Time
+	lastPlain time.Time
+}
+
+// stdoutIsTerminal decides whether the \r-rewriting bar is safe; piped
+// into a log it turns into one long garbled line.
+var stdoutIsTerminal = func() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}()
+
+// plainMode reports whether to print periodic status lines instead of
+// the bar: asked for with -plain/-no-progress, or forced when stdout
+// isn't a terminal (cron, CI). NO_COLOR needs no special handling —
+// copysort never emits color.
+func plainMode() bool {
+	return opts.plain || !stdoutIsTerminal
+}
+
+func newProgress(total int) *progress {
+	return &progress{total: total, started: time.Now()}
+}
+
+func (p *progress) tick(bytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.count++
+	p.bytes += bytes
+	if time.Since(p.last) < 100*time.Millisecond && p.count != p.total {
+		return
+	}
+	p.last = time.Now()
+	elapsed := time.Since(p.started)
+	ev := progEvent{
+		Event:   "progress",
+		Done:    p.count,
+		Total:   p.total,
+		Bytes:   p.bytes,
+		Rate:    int64(float64(p.bytes) / elapsed.Seconds()),
+		Elapsed: elapsed.Seconds(),
+	}
+	progSockEmit(ev)
+	mqttEmit(ev)
+	p.draw()
+}
+
+func (p *progress) draw() {
+	if opts.verbose || opts.tui || opts.quiet {
+		return // the bar would fight verbose logging or the TUI
+	}
+	elapsed := time.Since(p.started).Seconds()
+	rate := float64(p.bytes) / elapsed
+	if plainMode() {
+		if time.Since(p.lastPlain) < 2*time.Second && p.count != p.total {
+			return
+		}
+		p.lastPlain = time.Now()
+		pct := 0
+		if p.total > 0 {
+			pct = 100 * p.count / p.total
+		}
+		fmt.Printf("copysort: %d/%d files (%d%%), %s, %s/s\n",
+			p.count, p.total, pct, humanBytes(p.bytes), humanBytes(int64(rate)))
+		return
+	}
+	const width = 30
+	filled := 0
+	if p.total > 0 {
+		filled = width * p.count / p.total
+	}
+	bar := ""
+	for i := 0; i < width; i++ {
+		if i < filled {
+			bar += "="
+		} else {
+			bar += " "
+		}
+	}
+	fmt.Printf("\r[%s] %d/%d %s/s   ", bar, p.count, p.total, humanBytes(int64(rate)))
+}
+
+func (p *progress) done() {
+	if !opts.verbose && !opts.tui && !plainMode() {
+		fmt.Println() // finish the \r line
+	}
+}
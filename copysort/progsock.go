@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Progress socket: -progress-socket PATH streams newline-delimited JSON
+// progress events so a desktop widget or home-automation setup can show
+// the import without scraping the terminal. If PATH is an existing named
+// pipe the events are written into it; otherwise copysort listens on a
+// unix socket there and streams to every client that connects. One
+// "start" event opens the run, rate-limited "progress" events follow,
+// and a "done" event carries the final counts.
+
+type progEvent struct {
+	Event   string  `json:"event"`
+	Total   int     `json:"total,omitempty"`
+	Copied  int64   `json:"copied,omitempty"`
+	Skipped int64   `json:"skipped,omitempty"`
+	Errors  int64   `json:"errors,omitempty"`
+	Done    int     `json:"done,omitempty"`
+	Bytes   int64   `json:"bytes,omitempty"`
+	Rate    int64   `json:"rate,omitempty"`
+	Elapsed float64 `json:"elapsed,omitempty"`
+}
+
+var progSock struct {
+	mu    sync.Mutex
+	pipe  *os.File
+	ln    net.Listener
+	conns []net.Conn
+	path  string
+}
+
+func initProgSock(path string, total int) error {
+	if path == "" {
+		return nil
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeNamedPipe != 0 {
+		// O_RDWR keeps the open from blocking until a reader shows up
+		// (and from EPIPE if the reader goes away mid-run).
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		progSock.pipe = f
+	} else {
+		os.Remove(path) // a stale socket from a crashed run
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return err
+		}
+		progSock.ln = ln
+		progSock.path = path
+		go func() {
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				progSock.mu.Lock()
+				progSock.conns = append(progSock.conns, c)
+				progSock.mu.Unlock()
+			}
+		}()
+	}
+	progSockEmit(progEvent{Event: "start", Total: total})
+	return nil
+}
+
+func progSockEmit(ev progEvent) {
+	if progSock.pipe == nil && progSock.ln == nil {
+		return
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	progSock.mu.Lock()
+	defer progSock.mu.Unlock()
+	if progSock.pipe != nil {
+		progSock.pipe.Write(line)
+		return
+	}
+	alive := progSock.conns[:0]
+	for _, c := range progSock.conns {
+		if _, err := c.Write(line); err != nil {
+			c.Close()
+			continue
+		}
+		alive = append(alive, c)
+	}
+	progSock.conns = alive
+}
+
+// progSockDone emits the final counts and tears the socket down.
+func progSockDone(st *stats, elapsed time.Duration) {
+	progSockEmit(progEvent{
+		Event:   "done",
+		Copied:  st.copied.Load(),
+		Skipped: st.skipped.Load(),
+		Errors:  st.errors.Load(),
+		Bytes:   st.bytes.Load(),
+		Elapsed: elapsed.Seconds(),
+	})
+	progSock.mu.Lock()
+	defer progSock.mu.Unlock()
+	if progSock.pipe != nil {
+		progSock.pipe.Close()
+		progSock.pipe = nil
+	}
+	if progSock.ln != nil {
+		progSock.ln.Close()
+		progSock.ln = nil
+		for _, c := range progSock.conns {
+			c.Close()
+		}
+		progSock.conns = nil
+		os.Remove(progSock.path)
+	}
+}
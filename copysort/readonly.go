@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Source trees are strictly read-only: temp files are created next to
+// their destination (or in the system temp dir for archive spools), and
+// on Linux sources are opened with O_NOATIME so even access times stay
+// put. Overlapping roots are always refused — a destination inside a
+// source would make the walk pick up files this run just wrote and
+// duplicate them without end. -assert-readonly goes one step further
+// for cautious setups and also refuses sources that merely share the
+// destination's volume.
+
+// checkOverlap refuses a destination that contains, equals or lives
+// inside any source root. It runs on every invocation.
+func checkOverlap(srcRoots []string, destRoot string) error {
+	dest, err := resolveRoot(destRoot)
+	if err != nil {
+		return err
+	}
+	for _, root := range srcRoots {
+		src, err := resolveRoot(root)
+		if err != nil {
+			return err
+		}
+		if pathContains(src, dest) || pathContains(dest, src) {
+			return fmt.Errorf("source %s overlaps destination %s", root, destRoot)
+		}
+	}
+	return nil
+}
+
+// assertReadonly adds the stricter same-volume refusal on top of the
+// overlap check.
+func assertReadonly(srcRoots []string, destRoot string) error {
+	dest, err := resolveRoot(destRoot)
+	if err != nil {
+		return err
+	}
+	for _, root := range srcRoots {
+		src, err := resolveRoot(root)
+		if err != nil {
+			return err
+		}
+		same, err := sameVolume(src, dest)
+		if err != nil {
+			return err
+		}
+		if same {
+			return fmt.Errorf("source %s and destination %s are on the same volume", root, destRoot)
+		}
+	}
+	return nil
+}
+
+// resolveRoot canonicalizes a root so symlinked spellings of the same
+// directory still compare equal.
+func resolveRoot(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	if r, err := filepath.EvalSymlinks(abs); err == nil {
+		return r, nil
+	}
+	return abs, nil
+}
+
+// pathContains reports whether inner is outer or lives beneath it.
+func pathContains(outer, inner string) bool {
+	if outer == inner {
+		return true
+	}
+	return strings.HasPrefix(inner, outer+string(filepath.Separator))
+}
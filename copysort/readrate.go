@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Read-rate anomaly detection: a file that reads at a small fraction of
+// its card's typical speed is often the first sign of failing flash —
+// the controller retries marginal sectors until the data limps out, long
+// before reads start failing outright. Every copy's throughput is
+// recorded against its source root, and a post-run report flags files
+// far below that root's median, so a dying card gets recovered while it
+// still reads at all. Always on: it costs a timestamp per file.
+
+const (
+	// readRateMinSize keeps tiny files out of the pool; below this,
+	// per-file overhead drowns the throughput signal.
+	readRateMinSize = 256 * 1024
+
+	// readRateMinSamples is the fewest files a root needs before its
+	// median means anything.
+	readRateMinSamples = 8
+
+	// readRateFactor flags files slower than median/readRateFactor —
+	// generous enough that fragmentation and small-file overhead never
+	// trip it, while a sector-retrying card (often 100x slower) does.
+	readRateFactor = 8
+)
+
+type readRateSample struct {
+	path string
+	rate float64 // bytes per second
+}
+
+var readRates struct {
+	mu     sync.Mutex
+	byRoot map[string][]readRateSample
+}
+
+// recordReadRate notes one copy's throughput. Retried files are left
+// out; their timing includes the failures.
+func recordReadRate(j *job, d time.Duration) {
+	if j.size < readRateMinSize || d <= 0 || j.attempts > 0 {
+		return
+	}
+	s := readRateSample{j.srcPath, float64(j.size) / d.Seconds()}
+	readRates.mu.Lock()
+	if readRates.byRoot == nil {
+		readRates.byRoot = map[string][]readRateSample{}
+	}
+	readRates.byRoot[j.srcRoot] = append(readRates.byRoot[j.srcRoot], s)
+	readRates.mu.Unlock()
+}
+
+// reportSlowReads warns about files that read pathologically slowly
+// compared to the rest of their source root, slowest first.
+func reportSlowReads() {
+	readRates.mu.Lock()
+	defer readRates.mu.Unlock()
+	for root, samples := range readRates.byRoot {
+		if len(samples) < readRateMinSamples {
+			continue
+		}
+		rates := make([]float64, len(samples))
+		for i, s := range samples {
+			rates[i] = s.rate
+		}
+		sort.Float64s(rates)
+		median := rates[len(rates)/2]
+		var slow []readRateSample
+		for _, s := range samples {
+			if s.rate < median/readRateFactor {
+				slow = append(slow, s)
+			}
+		}
+		if len(slow) == 0 {
+			continue
+		}
+		sort.Slice(slow, func(a, b int) bool { return slow[a].rate < slow[b].rate })
+		warnf("%s: %d files read far below the medium's typical %s/s — failing card? recover its data now\n",
+			root, len(slow), humanBytes(int64(median)))
+		for i, s := range slow {
+			if i == 10 {
+				warnf("  ... and %d more\n", len(slow)-10)
+				break
+			}
+			warnf("  %s (%s/s)\n", s.path, humanBytes(int64(s.rate)))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// `copysort report DEST` audits an archive this tool built: photos per
+// year and month, bytes per camera model, the largest files, and the
+// busiest shooting days. It works from the destination tree itself
+// rather than the manifest, so it also covers files that arrived by
+// other means. When the archive carries a .copysort-exifindex (written
+// by -exif-index) camera models come from the index instead of
+// re-parsing EXIF out of every file.
+
+var dateDirRe = regexp.MustCompile(`^(\d{4})/(\d{2})(?:/(\d{2}))?/`)
+
+func runReport(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: copysort report DEST")
+		os.Exit(2)
+	}
+	initLog("") // silence the walker's per-file debug chatter
+	root := args[0]
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		fmt.Fprintf(os.Stderr, "copysort: %s is not a directory\n", root)
+		os.Exit(2)
+	}
+
+	var jobs []job
+	w := &walker{root: root, jobs: &jobs}
+	if err := w.walk(root); err != nil {
+		fmt.Fprintf(os.Stderr, "copysort: %v\n", err)
+		os.Exit(1)
+	}
+	idx := loadExifIndex(root)
+
+	years := map[string]*tally{}
+	months := map[string]*tally{}
+	days := map[string]int{}
+	models := map[string]*tally{}
+	var total tally
+	for i := range jobs {
+		j := &jobs[i]
+		if !isMedia(j.relPath) {
+			continue
+		}
+		total.files++
+		total.bytes += j.size
+		if m := dateDirRe.FindStringSubmatch(j.relPath); m != nil {
+			bumpTally(years, m[1], j.size)
+			bumpTally(months, m[1]+"/"+m[2], j.size)
+			if m[3] != "" {
+				days[m[1]+"/"+m[2]+"/"+m[3]]++
+			}
+		}
+		var model string
+		if e, ok := idx[j.relPath]; ok {
+			model = e.model
+		} else {
+			_, _, model = extractTimestamp(j.srcPath, path.Base(j.relPath))
+		}
+		if model == "" {
+			model = "(unknown)"
+		}
+		bumpTally(models, model, j.size)
+	}
+
+	fmt.Printf("%s: %d media files, %s\n", root, total.files, humanBytes(total.bytes))
+	if len(years) > 0 {
+		fmt.Println("by year:")
+		for _, k := range sortedKeys(years) {
+			t := years[k]
+			fmt.Printf("  %-8s %6d files  %s\n", k, t.files, humanBytes(t.bytes))
+		}
+		fmt.Println("by month:")
+		for _, k := range sortedKeys(months) {
+			t := months[k]
+			fmt.Printf("  %-8s %6d files  %s\n", k, t.files, humanBytes(t.bytes))
+		}
+	}
+	if len(models) > 0 {
+		fmt.Println("by camera:")
+		keys := sortedKeys(models)
+		sort.SliceStable(keys, func(a, b int) bool {
+			return models[keys[a]].bytes > models[keys[b]].bytes
+		})
+		for _, k := range keys {
+			t := models[k]
+			fmt.Printf("  %6d files  %8s  %s\n", t.files, humanBytes(t.bytes), k)
+		}
+	}
+	if len(days) > 0 {
+		type dayCount struct {
+			day string
+			n   int
+		}
+		top := make([]dayCount, 0, len(days))
+		for d, n := range days {
+			top = append(top, dayCount{d, n})
+		}
+		sort.Slice(top, func(a, b int) bool {
+			if top[a].n != top[b].n {
+				return top[a].n > top[b].n
+			}
+			return top[a].day < top[b].day
+		})
+		if len(top) > 10 {
+			top = top[:10]
+		}
+		fmt.Println("busiest days:")
+		for _, d := range top {
+			fmt.Printf("  %s  %6d files\n", d.day, d.n)
+		}
+	}
+	media := jobs[:0]
+	for i := range jobs {
+		if isMedia(jobs[i].relPath) {
+			media = append(media, jobs[i])
+		}
+	}
+	sort.Slice(media, func(a, b int) bool { return media[a].size > media[b].size })
+	if len(media) > 0 {
+		fmt.Println("largest files:")
+		n := len(media)
+		if n > 10 {
+			n = 10
+		}
+		for _, j := range media[:n] {
+			fmt.Printf("  %8s  %s\n", humanBytes(j.size), j.relPath)
+		}
+	}
+	os.Exit(0)
+}
+
+func bumpTally(m map[string]*tally, k string, size int64) {
+	t := m[k]
+	if t == nil {
+		t = &tally{}
+		m[k] = t
+	}
+	t.files++
+	t.bytes += size
+}
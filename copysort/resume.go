@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -resume saves the scanned, ordered job list next to the manifest once
+// the run is confirmed, so restarting after a crash picks up the saved
+// plan instead of re-walking a multi-million-file source tree. The file
+// is append-only: the initial dump records every job, and the extract
+// pool appends an updated line as each file's metadata lands, so a
+// resumed run also skips the EXIF work already done. Files copied
+// before the crash are excluded by the manifest as usual. The file is
+// deleted after a run with no errors; after a failure it stays, and the
+// next -resume run continues from it.
+
+const resumeName = ".copysort-resume"
+
+var resume struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// resumeLine formats one job (or companion) record. Path columns are
+// quoted so they can't smuggle in tabs; kind is "j" for a job, "c" for
+// a companion of the preceding job.
+func resumeLine(kind string, j *job) string {
+	mtime := int64(0)
+	if !j.mtime.IsZero() {
+		mtime = j.mtime.UnixNano()
+	}
+	when := int64(0)
+	source, model := "", ""
+	if j.meta != nil {
+		source, model = j.meta.source, j.meta.model
+		if !j.meta.when.IsZero() {
+			when = j.meta.when.UnixNano()
+		}
+	}
+	return fmt.Sprintf("%s\t%d\t%d\t%d\t%s\t%s\t%q\t%q\t%q\t%q\t%q\t%q",
+		kind, j.size, mtime, when, source, model,
+		j.symlink, j.subdir, j.entryName, j.srcRoot, j.srcPath, j.relPath)
+}
+
+func parseResumeLine(line string) (kind string, j job, ok bool) {
+	parts := strings.Split(line, "\t")
+	if len(parts) != 12 {
+		return "", job{}, false
+	}
+	size, err1 := strconv.ParseInt(parts[1], 10, 64)
+	mtime, err2 := strconv.ParseInt(parts[2], 10, 64)
+	when, err3 := strconv.ParseInt(parts[3], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return "", job{}, false
+	}
+	var paths [6]string
+	for i, p := range parts[6:] {
+		s, err := strconv.Unquote(p)
+		if err != nil {
+			return "", job{}, false
+		}
+		paths[i] = s
+	}
+	j = job{
+		srcPath: paths[4], srcRoot: paths[3], relPath: paths[5],
+		size: size, entryName: paths[2], subdir: paths[1], symlink: paths[0],
+	}
+	if mtime != 0 {
+		j.mtime = time.Unix(0, mtime)
+	}
+	if parts[4] != "" {
+		m := &fileMeta{source: parts[4], model: parts[5]}
+		if when != 0 {
+			m.when = time.Unix(0, when)
+		}
+		j.meta = m
+	}
+	return parts[0], j, true
+}
+
+func resumeKey(j *job) string {
+	return j.srcPath + "\x00" + j.relPath + "\x00" + j.entryName
+}
+
+func resumeHeader(srcRoots []string) string {
+	h := "#copysort-resume"
+	for _, r := range srcRoots {
+		h += fmt.Sprintf("\t%q", r)
+	}
+	return h
+}
+
+// writeResume dumps the confirmed job list. Companions follow their
+// owning job as "c" lines.
+func writeResume(jobs []job, srcRoots []string, destRoot string) error {
+	f, err := os.Create(filepath.Join(destRoot, resumeName))
+	if err != nil {
+		return fmt.Errorf("write resume file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, resumeHeader(srcRoots))
+	for i := range jobs {
+		fmt.Fprintln(w, resumeLine("j", &jobs[i]))
+		for _, c := range jobs[i].companions {
+			fmt.Fprintln(w, resumeLine("c", c))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write resume file: %w", err)
+	}
+	resume.f = f
+	return nil
+}
+
+// resumeNote re-appends a job once its metadata is extracted; on load
+// the newest line for a job wins, so a resumed run skips the parse.
+func resumeNote(j *job) {
+	if resume.f == nil {
+		return
+	}
+	resume.mu.Lock()
+	fmt.Fprintln(resume.f, resumeLine("j", j))
+	resume.mu.Unlock()
+}
+
+// loadResume reads a saved job list back, newest line per job winning.
+// It refuses a plan saved for a different set of source roots.
+func loadResume(srcRoots []string, destRoot string) ([]job, bool) {
+	f, err := os.Open(filepath.Join(destRoot, resumeName))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	if !sc.Scan() {
+		return nil, false
+	}
+	if sc.Text() != resumeHeader(srcRoots) {
+		warnf("resume: saved plan is for different sources, rescanning\n")
+		return nil, false
+	}
+	var jobs []job
+	idx := map[string]int{}
+	last := -1
+	for sc.Scan() {
+		kind, j, ok := parseResumeLine(sc.Text())
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "j":
+			if i, ok := idx[resumeKey(&j)]; ok {
+				j.companions = jobs[i].companions
+				jobs[i] = j
+				last = i
+			} else {
+				jobs = append(jobs, j)
+				last = len(jobs) - 1
+				idx[resumeKey(&j)] = last
+			}
+		case "c":
+			if last >= 0 {
+				c := j
+				jobs[last].companions = append(jobs[last].companions, &c)
+			}
+		}
+	}
+	if sc.Err() != nil || len(jobs) == 0 {
+		return nil, false
+	}
+	return jobs, true
+}
+
+// clearResume removes the saved plan after a clean run; a run with
+// errors keeps it for the next resume.
+func clearResume(destRoot string) {
+	if resume.f != nil {
+		resume.f.Close()
+		resume.f = nil
+	}
+	os.Remove(filepath.Join(destRoot, resumeName))
+}
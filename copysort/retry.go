@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Transient-error retry: reads from SMB/NFS sources fail in ways that
+// often heal themselves — EIO from a wobbly mount, stale NFS handles,
+// timeouts. Instead of counting such a file as failed on the first
+// attempt, it is re-queued and retried after the main pass, up to
+// -retries times per file. Permanent errors (ENOENT, EACCES, corrupt
+// data) still fail immediately.
+
+var retryQ struct {
+	mu   sync.Mutex
+	jobs []*job
+}
+
+// transientErr reports whether an error is worth retrying.
+func transientErr(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	switch errno {
+	case syscall.EIO, syscall.ESTALE, syscall.ETIMEDOUT,
+		syscall.EAGAIN, syscall.EINTR, syscall.ENOTCONN, syscall.ECONNRESET:
+		return true
+	}
+	return false
+}
+
+// requeue puts a job back on the retry queue without charging it an
+// attempt — used when the failure was the destination's fault, not the
+// file's.
+func requeue(j *job) {
+	j.queued = true
+	retryQ.mu.Lock()
+	retryQ.jobs = append(retryQ.jobs, j)
+	retryQ.mu.Unlock()
+}
+
+// retryLater re-queues a job for the post-run retry pass if its error is
+// transient and it has attempts left; it reports whether it did.
+func retryLater(j *job, err error) bool {
+	if !transientErr(err) || j.attempts >= opts.retries {
+		return false
+	}
+	j.attempts++
+	j.queued = true
+	retryQ.mu.Lock()
+	retryQ.jobs = append(retryQ.jobs, j)
+	retryQ.mu.Unlock()
+	warnf("retry %s: %v (attempt %d of %d)\n", j.srcPath, err, j.attempts, opts.retries)
+	return true
+}
+
+// lastChanceRetry queues a file whose error wasn't transient for one
+// final attempt after the main pass. Flaky card readers fail under
+// eight parallel readers but often succeed alone, so every failure gets
+// a single sequential go — with fresh handles and fresh metadata —
+// before it is reported as a failure.
+func lastChanceRetry(j *job, err error) bool {
+	if j.final {
+		return false
+	}
+	j.final = true
+	j.meta = nil
+	j.queued = true
+	retryQ.mu.Lock()
+	retryQ.jobs = append(retryQ.jobs, j)
+	retryQ.mu.Unlock()
+	warnf("final retry %s: %v\n", j.srcPath, err)
+	return true
+}
+
+func drainRetries() []*job {
+	retryQ.mu.Lock()
+	defer retryQ.mu.Unlock()
+	jobs := retryQ.jobs
+	retryQ.jobs = nil
+	return jobs
+}
+
+// runRetries processes re-queued jobs sequentially — a source that just
+// produced I/O errors gets gentler treatment than 8 parallel readers.
+func runRetries(destRoot string, m *manifest, st *stats, prog *progress) {
+	for {
+		batch := drainRetries()
+		if len(batch) == 0 {
+			return
+		}
+		for _, j := range batch {
+			gate.wait()
+			j.queued = false
+			processJob(j, destRoot, m, st)
+			if !j.queued {
+				prog.tick(j.size)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Per-extension destination routing: -routes FILE splits one run across
+// several destination roots, e.g. videos onto a big spinning disk while
+// photos land on SSD. Each non-comment line is
+//
+//	<exts> = <destination root>
+//
+// where <exts> is a comma-separated list of extensions and/or the group
+// names photos, videos, raws:
+//
+//	videos, mkv = /mnt/spinning/video
+//	photos = /mnt/ssd/photos
+//	pdf, docx = /srv/documents
+//
+// Files with no matching route go under the DEST argument as usual, and
+// run-level artifacts (manifest, SHA256SUMS, albums index) stay there
+// too so the next run finds them in one place.
+
+var routeTable map[string]string
+
+func loadRoutes(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("routes: %w", err)
+	}
+	defer f.Close()
+	routeTable = map[string]string{}
+	sc := bufio.NewScanner(f)
+	lineno := 0
+	for sc.Scan() {
+		lineno++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		exts, root, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("routes: %s:%d: want \"exts = root\"", path, lineno)
+		}
+		root = strings.TrimSpace(root)
+		if root == "" {
+			return fmt.Errorf("routes: %s:%d: empty destination", path, lineno)
+		}
+		if err := os.MkdirAll(root, 0o755); err != nil {
+			return fmt.Errorf("routes: %w", err)
+		}
+		for _, tok := range strings.Split(exts, ",") {
+			tok = strings.ToLower(strings.TrimSpace(tok))
+			switch tok {
+			case "":
+				continue
+			case "photos":
+				for ext := range photoExts {
+					if !rawExts[ext] {
+						routeTable[ext] = root
+					}
+				}
+			case "videos":
+				for ext := range videoExts {
+					routeTable[ext] = root
+				}
+			case "raws":
+				for ext := range rawExts {
+					routeTable[ext] = root
+				}
+			default:
+				routeTable["."+strings.TrimPrefix(tok, ".")] = root
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// routeDest returns the destination root for a file, falling back to the
+// run's DEST argument when no route matches its extension.
+func routeDest(relPath, destRoot string) string {
+	if len(routeTable) == 0 {
+		return destRoot
+	}
+	if root, ok := routeTable[strings.ToLower(filepath.Ext(relPath))]; ok {
+		return root
+	}
+	return destRoot
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Long imports outlive terminals: -run-log FILE appends a timestamped
+// checkpoint line every -checkpoint interval, plus a final line when the
+// run ends, so if a multi-hour import dies or the scrollback is lost
+// the progress it made — and the rate it was making — are still on
+// disk. The rate on each line covers the interval since the previous
+// one, not the whole run, so a slowdown shows up as it happens.
+
+var runLog struct {
+	f  *os.File
+	mu sync.Mutex
+
+	lastBytes int64
+	lastTime  time.Time
+}
+
+func initRunLog(st *stats) error {
+	if opts.runLog == "" {
+		return nil
+	}
+	if opts.checkpoint <= 0 {
+		return fmt.Errorf("invalid -checkpoint %v", opts.checkpoint)
+	}
+	f, err := os.OpenFile(opts.runLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open run log: %w", err)
+	}
+	runLog.f = f
+	runLog.lastTime = time.Now()
+	fmt.Fprintf(f, "%s run started, pid %d\n", time.Now().Format(time.RFC3339), os.Getpid())
+	go func() {
+		for range time.Tick(opts.checkpoint) {
+			logCheckpoint(st, "checkpoint")
+		}
+	}()
+	return nil
+}
+
+func logCheckpoint(st *stats, label string) {
+	if runLog.f == nil {
+		return
+	}
+	runLog.mu.Lock()
+	defer runLog.mu.Unlock()
+	now := time.Now()
+	bytes := st.bytes.Load()
+	rate := "-"
+	if dt := now.Sub(runLog.lastTime); dt > 0 && bytes > runLog.lastBytes {
+		rate = humanBytes(int64(float64(bytes-runLog.lastBytes)/dt.Seconds())) + "/s"
+	}
+	fmt.Fprintf(runLog.f, "%s %s: %d copied, %d skipped, %d errors, %s, %s\n",
+		now.Format(time.RFC3339), label,
+		st.copied.Load(), st.skipped.Load(), st.errors.Load(),
+		humanBytes(bytes), rate)
+	runLog.lastBytes, runLog.lastTime = bytes, now
+}
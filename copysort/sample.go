@@ -0,0 +1,43 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// -sample N trims the run to N randomly chosen pending files, so a new
+// rename template, filter, or destination layout can be validated on a
+// handful of real files before committing to the full import. Files the
+// manifest already covers don't count toward the sample (they would
+// only produce skips); companions travel with their primaries, so a
+// sampled Live Photo or RAW+JPEG pair still arrives whole. Everything
+// downstream — sorting, renaming, conversion — runs exactly as a full
+// import would.
+func sampleJobs(jobs []job, n int, m *manifest) []job {
+	if n <= 0 || n >= len(jobs) {
+		return jobs
+	}
+	idx := make([]int, 0, len(jobs))
+	for i := range jobs {
+		j := &jobs[i]
+		// Checking the manifest here is only cheap with path keys;
+		// hash keys would mean hashing every source just to sample.
+		if opts.manifestKey == "path" && m.has(m.key(j)) && m.fresh(j) {
+			continue
+		}
+		idx = append(idx, i)
+	}
+	pending := len(idx)
+	if n >= pending {
+		n = pending
+	}
+	rand.Shuffle(len(idx), func(a, b int) { idx[a], idx[b] = idx[b], idx[a] })
+	idx = idx[:n]
+	sort.Ints(idx) // keep the walk order the rest of the pipeline expects
+	picked := make([]job, 0, n)
+	for _, i := range idx {
+		picked = append(picked, jobs[i])
+	}
+	infof("sampling %d of %d pending files\n", len(picked), pending)
+	return picked
+}
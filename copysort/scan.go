@@ -0,0 +1,515 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// job is one source file waiting to be copied.
+type job struct {
+	srcPath string // absolute (or as-given) path to the source file
+	srcRoot string // the source root it was found under
+	relPath string // path relative to srcRoot, forward slashes
+	size    int64
+	mtime   time.Time
+
+	// entryName is set when the source is inside a zip/tar archive; the
+	// worker spools the entry to a temp file before processing it.
+	entryName string
+
+	// companions are files that must travel with this one — the .mov
+	// half of a Live Photo, or the JPEG half of a RAW+JPEG pair. They
+	// are copied into the same date folder with this job's final stem.
+	companions []*job
+
+	// subdir is an extra folder inserted between the date directory and
+	// the filename (e.g. "RAW" or "JPEG" for the split pair policy).
+	subdir string
+
+	// attempts counts transient-error retries already spent on this file.
+	attempts int
+
+	// final marks that the one end-of-run retry granted to every failed
+	// file has been used.
+	final bool
+
+	// queued marks that the job is sitting on the retry queue; the
+	// progress tick is deferred to the attempt that actually finishes
+	// it, so a retried file is only counted once.
+	queued bool
+
+	// meta is the capture metadata pre-computed by the extract pool, or
+	// nil when the copy worker must extract it itself (archive entries,
+	// retries).
+	meta *fileMeta
+
+	// symlink is the link target when -preserve-symlinks found a symlink;
+	// the worker recreates the link in the unsorted tree instead of
+	// copying anything.
+	symlink string
+
+	// mkey caches the content hash used as this file's manifest key
+	// under -manifest-key=hash, so it is computed at most once.
+	mkey string
+}
+
+// photoExts and videoExts are the extensions copysort will try to date-sort.
+// Everything else goes through the unsorted relative-path tree.
+var photoExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+	".heic": true, ".heif": true, ".tif": true, ".tiff": true,
+	".avif": true, ".jxl": true,
+	".dng": true, ".cr2": true, ".cr3": true, ".nef": true,
+	".arw": true, ".orf": true, ".rw2": true, ".raf": true,
+}
+
+var videoExts = map[string]bool{
+	".mp4": true, ".mov": true, ".avi": true, ".m4v": true,
+	".3gp": true, ".mpg": true, ".mpeg": true,
+	".mkv": true, ".webm": true, ".mts": true, ".m2ts": true,
+	// Drone and action-cam containers: Insta360 .insv and GoPro
+	// .360/.lrv are ISO-BMFF underneath, so the regular mvhd walk
+	// dates them.
+	".insv": true, ".360": true, ".lrv": true,
+}
+
+func isMedia(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return photoExts[ext] || videoExts[ext]
+}
+
+// scanSources walks every source root and returns the list of files to
+// consider. Per-file problems are reported by the copy workers later; a
+// walk error on a root itself is fatal.
+func scanSources(roots []string) ([]job, error) {
+	var jobs []job
+	for _, root := range roots {
+		root := filepath.Clean(root)
+		if isArchiveSource(root) {
+			aj, err := scanArchive(root)
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, aj...)
+			continue
+		}
+		if isPhotosLibrary(root) {
+			if err := scanPhotosLibrary(root, &jobs); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		w := &walker{root: root, jobs: &jobs}
+		if opts.followSymlinks {
+			w.seen = map[string]bool{}
+		}
+		if err := w.walk(root); err != nil {
+			return nil, err
+		}
+	}
+	if opts.sniff {
+		sniffJobs(jobs)
+	}
+	if opts.appleExports {
+		jobs = appleExportFilter(jobs)
+	}
+	if opts.livePhotos {
+		jobs = pairLivePhotos(jobs)
+	}
+	jobs = pairRawJpeg(jobs)
+	if opts.groupBursts {
+		groupBursts(jobs)
+	}
+	return jobs, nil
+}
+
+// walker recursively scans one source root. It replaces filepath.WalkDir
+// so symlink behavior is explicit rather than whatever the default is:
+// symlinks are skipped by default, recreated as links in the unsorted
+// tree with -preserve-symlinks, or followed with -follow-symlinks (with
+// loop detection, for NAS sources full of link farms).
+type walker struct {
+	root string
+	jobs *[]job
+	seen map[string]bool // resolved dirs already entered; nil unless following
+}
+
+// systemJunk names OS droppings that are never worth archiving even when
+// hidden files are included.
+var systemJunk = map[string]bool{
+	"$RECYCLE.BIN":              true,
+	"System Volume Information": true,
+	"Thumbs.db":                 true,
+	"desktop.ini":               true,
+}
+
+// skipName filters hidden and system files: dotfiles (.DS_Store,
+// .Trashes, .Spotlight-V100, AppleDouble ._* files) unless
+// -include-hidden, and OS junk always.
+func skipName(name string) bool {
+	if systemJunk[name] {
+		return true
+	}
+	return !opts.includeHidden && strings.HasPrefix(name, ".")
+}
+
+func (w *walker) walk(dir string) error {
+	if w.seen != nil {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		if w.seen[real] {
+			warnf("skip %s (symlink loop)\n", dir)
+			return nil
+		}
+		w.seen[real] = true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		if skipName(e.Name()) {
+			debugf("skip %s (hidden/system)\n", p)
+			if !e.IsDir() {
+				recordSkip(p, "hidden/system")
+			}
+			continue
+		}
+		switch t := e.Type(); {
+		case t&fs.ModeSymlink != 0:
+			if err := w.symlink(p); err != nil {
+				return err
+			}
+		case t.IsDir():
+			if excludedDir(e.Name()) {
+				debugf("skip %s (-exclude-dir)\n", p)
+				recordSkip(p, "excluded dir")
+				continue
+			}
+			if opts.maxDepth > 0 && w.depth(p) >= opts.maxDepth {
+				debugf("skip %s (-max-depth)\n", p)
+				recordSkip(p, "max depth")
+				continue
+			}
+			if err := w.walk(p); err != nil {
+				return err
+			}
+		case t.IsRegular():
+			info, err := e.Info()
+			if err != nil {
+				return err
+			}
+			if err := w.add(p, info); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *walker) symlink(p string) error {
+	if opts.preserveSymlinks {
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(w.root, p)
+		if err != nil {
+			return err
+		}
+		*w.jobs = append(*w.jobs, job{
+			srcPath: p,
+			srcRoot: w.root,
+			relPath: filepath.ToSlash(rel),
+			symlink: target,
+		})
+		return nil
+	}
+	if !opts.followSymlinks {
+		return nil
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		warnf("skip %s (broken symlink)\n", p)
+		return nil
+	}
+	if info.IsDir() {
+		return w.walk(p)
+	}
+	if info.Mode().IsRegular() {
+		return w.add(p, info)
+	}
+	return nil
+}
+
+// depth counts how many levels below the walk root a directory sits:
+// the root's immediate children are 1. -max-depth N keeps files at most
+// N levels down, so a directory at depth N is never entered.
+func (w *walker) depth(dir string) int {
+	rel, err := filepath.Rel(w.root, dir)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(os.PathSeparator)) + 1
+}
+
+// excludedDir applies the repeatable -exclude-dir flag: directories
+// with a matching name are skipped wherever they appear in the walk.
+func excludedDir(name string) bool {
+	for _, d := range opts.excludeDirs {
+		if name == d {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeFiltered applies -min-size/-max-size; zero means no bound.
+func sizeFiltered(size int64) bool {
+	return size < minSizeBytes || (maxSizeBytes > 0 && size > maxSizeBytes)
+}
+
+var minSizeBytes, maxSizeBytes int64
+
+func initSizeFilters() error {
+	if opts.minSize != "" {
+		n, err := parseSize(opts.minSize)
+		if err != nil {
+			return fmt.Errorf("-min-size: %w", err)
+		}
+		minSizeBytes = int64(n)
+	}
+	if opts.maxSize != "" {
+		n, err := parseSize(opts.maxSize)
+		if err != nil {
+			return fmt.Errorf("-max-size: %w", err)
+		}
+		maxSizeBytes = int64(n)
+	}
+	return nil
+}
+
+func (w *walker) add(p string, info fs.FileInfo) error {
+	if sizeFiltered(info.Size()) {
+		debugf("skip %s (size filter)\n", p)
+		recordSkip(p, "size filter")
+		return nil
+	}
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil {
+		return err
+	}
+	*w.jobs = append(*w.jobs, job{
+		srcPath: p,
+		srcRoot: w.root,
+		relPath: filepath.ToSlash(rel),
+		size:    info.Size(),
+		mtime:   info.ModTime(),
+	})
+	return nil
+}
+
+// orderJobs applies the -order scheduling policy. smallest-first gets the
+// thousands of small JPEGs safely onto the destination in the first
+// minutes of an import, before the tool spends an hour on videos — cheap
+// insurance against a flaky card reader.
+func orderJobs(jobs []job) error {
+	switch opts.order {
+	case "source":
+		// keep walk order
+	case "smallest-first":
+		sort.SliceStable(jobs, func(a, b int) bool { return jobs[a].size < jobs[b].size })
+	case "largest-first":
+		sort.SliceStable(jobs, func(a, b int) bool { return jobs[a].size > jobs[b].size })
+	case "photos-first":
+		rank := func(j *job) int {
+			ext := strings.ToLower(path.Ext(j.relPath))
+			switch {
+			case photoExts[ext]:
+				return 0
+			case videoExts[ext]:
+				return 1
+			default:
+				return 2
+			}
+		}
+		sort.SliceStable(jobs, func(a, b int) bool { return rank(&jobs[a]) < rank(&jobs[b]) })
+	default:
+		return fmt.Errorf("invalid -order %q (want source, smallest-first, largest-first or photos-first)", opts.order)
+	}
+	return nil
+}
+
+const (
+	burstMinLen = 8               // shots before a sequence counts as a burst
+	burstMaxGap = 3 * time.Second // max mtime gap between consecutive shots
+)
+
+var seqRe = regexp.MustCompile(`^(.*?)(\d{3,})$`)
+
+// groupBursts detects bursts/brackets — runs of consecutive sequence
+// numbers shot within a few seconds of each other — and routes each one
+// into a per-burst subfolder of its date directory, so a 40-shot burst
+// doesn't swamp the day folder. Detection uses filename sequence numbers
+// plus mtime proximity; that covers every camera that numbers its frames
+// without needing an EXIF read during the scan.
+func groupBursts(jobs []job) {
+	type shot struct {
+		idx    int
+		prefix string
+		num    int
+	}
+	byDir := map[string][]shot{}
+	for i := range jobs {
+		if !isMedia(jobs[i].relPath) || jobs[i].subdir != "" {
+			continue
+		}
+		stem := strings.TrimSuffix(path.Base(jobs[i].relPath), path.Ext(jobs[i].relPath))
+		m := seqRe.FindStringSubmatch(stem)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		byDir[path.Dir(jobs[i].relPath)+"\x00"+m[1]] = append(
+			byDir[path.Dir(jobs[i].relPath)+"\x00"+m[1]], shot{i, m[1], n})
+	}
+	for _, shots := range byDir {
+		sort.Slice(shots, func(a, b int) bool { return shots[a].num < shots[b].num })
+		for start := 0; start < len(shots); {
+			end := start + 1
+			for end < len(shots) &&
+				shots[end].num == shots[end-1].num+1 &&
+				absDuration(jobs[shots[end].idx].mtime.Sub(jobs[shots[end-1].idx].mtime)) <= burstMaxGap {
+				end++
+			}
+			if end-start >= burstMinLen {
+				first := jobs[shots[start].idx]
+				name := "burst_" + strings.TrimSuffix(path.Base(first.relPath), path.Ext(first.relPath))
+				for k := start; k < end; k++ {
+					jobs[shots[k].idx].subdir = name
+				}
+			}
+			start = end
+		}
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// livePhotoImageExts are the still-image halves a Live Photo can have.
+var livePhotoImageExts = map[string]bool{
+	".heic": true, ".heif": true, ".jpg": true, ".jpeg": true,
+}
+
+// pairLivePhotos finds Apple Live Photo pairs — a still image plus a .mov
+// with the same basename in the same directory — and attaches the video to
+// the image's job. Left on its own the .mov sorts by its own timestamp and
+// can land in a different day folder than its still.
+func pairLivePhotos(jobs []job) []job {
+	stills := map[string]int{} // dir + lowercase stem -> index into jobs
+	for i := range jobs {
+		ext := strings.ToLower(filepath.Ext(jobs[i].relPath))
+		if livePhotoImageExts[ext] {
+			stills[pairKey(jobs[i].relPath)] = i
+		}
+	}
+	paired := map[int]bool{} // mov indexes folded into a still's job
+	for i := range jobs {
+		if strings.ToLower(filepath.Ext(jobs[i].relPath)) == ".mov" {
+			if si, ok := stills[pairKey(jobs[i].relPath)]; ok {
+				mov := jobs[i]
+				jobs[si].companions = append(jobs[si].companions, &mov)
+				paired[i] = true
+			}
+		}
+	}
+	return dropJobs(jobs, paired)
+}
+
+// rawExts are the raw formats considered for RAW+JPEG pairing.
+var rawExts = map[string]bool{
+	".dng": true, ".cr2": true, ".cr3": true, ".nef": true,
+	".arw": true, ".orf": true, ".rw2": true, ".raf": true,
+}
+
+// pairRawJpeg applies the -raw-policy to cameras that write both
+// IMG_0001.CR3 and IMG_0001.JPG:
+//
+//	keep      pairs travel together; the RAW's capture date wins
+//	raw-only  drop the JPEG half of every pair
+//	jpeg-only drop the RAW half of every pair
+//	split     like keep, but into RAW/ and JPEG/ subfolders of the date dir
+func pairRawJpeg(jobs []job) []job {
+	raws := map[string]int{}
+	for i := range jobs {
+		if rawExts[strings.ToLower(filepath.Ext(jobs[i].relPath))] {
+			raws[pairKey(jobs[i].relPath)] = i
+		}
+	}
+	dropped := map[int]bool{}
+	for i := range jobs {
+		ext := strings.ToLower(filepath.Ext(jobs[i].relPath))
+		if ext != ".jpg" && ext != ".jpeg" {
+			continue
+		}
+		ri, ok := raws[pairKey(jobs[i].relPath)]
+		if !ok {
+			continue
+		}
+		switch opts.rawPolicy {
+		case "raw-only":
+			dropped[i] = true
+		case "jpeg-only":
+			dropped[ri] = true
+		case "split":
+			jobs[ri].subdir = "RAW"
+			fallthrough
+		default: // keep
+			jpeg := jobs[i]
+			if opts.rawPolicy == "split" {
+				jpeg.subdir = "JPEG"
+			}
+			// A Live Photo .mov already folded into the JPEG moves
+			// with it.
+			jobs[ri].companions = append(jobs[ri].companions, &jpeg)
+			jobs[ri].companions = append(jobs[ri].companions, jpeg.companions...)
+			jpeg.companions = nil
+			dropped[i] = true
+		}
+	}
+	return dropJobs(jobs, dropped)
+}
+
+func dropJobs(jobs []job, drop map[int]bool) []job {
+	if len(drop) == 0 {
+		return jobs
+	}
+	out := jobs[:0]
+	for i := range jobs {
+		if !drop[i] {
+			out = append(out, jobs[i])
+		}
+	}
+	return out
+}
+
+func pairKey(rel string) string {
+	ext := filepath.Ext(rel)
+	return strings.ToLower(strings.TrimSuffix(rel, ext))
+}
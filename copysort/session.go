@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Import-session tagging: -tag "hawaii-trip" labels everything a run
+// imports. The tag is recorded as a third manifest column, and every
+// destination folder that received files gets an import.json noting
+// which sessions contributed how many — so any archived file can later
+// be traced back to the import that brought it in.
+
+const sessionFileName = "import.json"
+
+// sessionEntry is one run's contribution to a destination folder.
+type sessionEntry struct {
+	Tag      string `json:"tag"`
+	Imported string `json:"imported"` // RFC 3339
+	Files    int    `json:"files"`
+}
+
+var session struct {
+	mu   sync.Mutex
+	dirs map[string]int // destination dir -> files added this run
+}
+
+// recordSession counts a copied file against its destination folder.
+func recordSession(destPath string) {
+	if opts.tag == "" {
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.dirs == nil {
+		session.dirs = map[string]int{}
+	}
+	session.dirs[filepath.Dir(destPath)]++
+}
+
+// writeSessionTags appends this run's entry to the import.json of every
+// folder it touched.
+func writeSessionTags(st *stats) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	now := time.Now().Format(time.RFC3339)
+	for dir, files := range session.dirs {
+		var doc struct {
+			Sessions []sessionEntry `json:"sessions"`
+		}
+		p := filepath.Join(dir, sessionFileName)
+		if b, err := os.ReadFile(p); err == nil {
+			json.Unmarshal(b, &doc) // a corrupt file starts over rather than aborting the run
+		}
+		doc.Sessions = append(doc.Sessions, sessionEntry{Tag: opts.tag, Imported: now, Files: files})
+		b, err := json.MarshalIndent(&doc, "", "  ")
+		if err == nil {
+			err = os.WriteFile(p, append(b, '\n'), 0o644)
+		}
+		if err != nil {
+			errorf("%s: %v\n", p, err)
+			st.fail()
+		}
+	}
+	session.dirs = nil
+}
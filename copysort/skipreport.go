@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// -skip-report FILE: the one-line summary says how many scanned files
+// were not copied, but not which or why. The report lists every one
+// with its reason (hidden/system, size filter, in manifest, hook,
+// duplicate, error ...) so the gap between scanned and copied can be
+// audited instead of trusted. Format follows the extension: .csv or
+// .json.
+
+var skipReport struct {
+	path string
+
+	mu      sync.Mutex
+	entries []skipEntry
+}
+
+type skipEntry struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func initSkipReport(path string) error {
+	if path == "" {
+		return nil
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".json":
+	default:
+		return fmt.Errorf("-skip-report %s: want a .csv or .json filename", path)
+	}
+	skipReport.path = path
+	return nil
+}
+
+// recordSkip notes one file that will not reach the destination.
+func recordSkip(path, reason string) {
+	if skipReport.path == "" {
+		return
+	}
+	skipReport.mu.Lock()
+	skipReport.entries = append(skipReport.entries, skipEntry{path, reason})
+	skipReport.mu.Unlock()
+}
+
+// writeSkipReport writes the collected entries at the end of the run.
+func writeSkipReport() error {
+	if skipReport.path == "" {
+		return nil
+	}
+	skipReport.mu.Lock()
+	defer skipReport.mu.Unlock()
+	sort.Slice(skipReport.entries, func(a, b int) bool {
+		return skipReport.entries[a].Path < skipReport.entries[b].Path
+	})
+	f, err := os.Create(skipReport.path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(skipReport.path), ".json") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(skipReport.entries)
+	} else {
+		w := csv.NewWriter(f)
+		err = w.Write([]string{"path", "reason"})
+		for _, e := range skipReport.entries {
+			if err != nil {
+				break
+			}
+			err = w.Write([]string{e.Path, e.Reason})
+		}
+		if err == nil {
+			w.Flush()
+			err = w.Error()
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source snapshots: -snapshot records what the sources looked like
+// (path, size, mtime) in the destination root each run, and on the next
+// run reports every source file that has since disappeared or changed —
+// an early warning for card corruption or accidental deletions while
+// the originals still exist. Only roots scanned this run are compared;
+// yesterday's card being absent today is not a loss. The fields go
+// size-first so paths containing tabs stay parseable.
+
+const snapshotName = ".copysort-snapshot"
+
+type snapEntry struct {
+	size  int64
+	mtime int64 // unix nanoseconds
+}
+
+func loadSnapshot(destRoot string) map[string]snapEntry {
+	f, err := os.Open(filepath.Join(destRoot, snapshotName))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	snap := map[string]snapEntry{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		parts := strings.SplitN(sc.Text(), "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		size, err1 := strconv.ParseInt(parts[0], 10, 64)
+		mt, err2 := strconv.ParseInt(parts[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		snap[parts[2]] = snapEntry{size, mt}
+	}
+	return snap
+}
+
+// checkSnapshot compares the previous snapshot against this run's scan,
+// warns about sources that vanished or changed, and writes the merged
+// snapshot back (keeping entries for roots not plugged in today).
+func checkSnapshot(jobs []job, srcRoots []string, destRoot string) {
+	old := loadSnapshot(destRoot)
+	current := map[string]snapEntry{}
+	for i := range jobs {
+		j := &jobs[i]
+		current[filepath.ToSlash(j.srcPath)] = snapEntry{j.size, j.mtime.UnixNano()}
+	}
+	roots := make([]string, len(srcRoots))
+	for i, r := range srcRoots {
+		roots[i] = filepath.ToSlash(filepath.Clean(r)) + "/"
+	}
+	underScanned := func(p string) bool {
+		for _, r := range roots {
+			if strings.HasPrefix(p, r) || strings.HasPrefix(p, strings.TrimSuffix(r, "/")+"!") {
+				return true
+			}
+		}
+		return false
+	}
+	missing, changed := 0, 0
+	for p, e := range old {
+		if !underScanned(p) {
+			current[p] = e // carry roots not scanned this run forward
+			continue
+		}
+		cur, ok := current[p]
+		switch {
+		case !ok:
+			warnf("source %s disappeared since last run\n", p)
+			missing++
+		case cur.size != e.size || !mtimeMatch(p, cur.mtime, e.mtime):
+			warnf("source %s changed since last run (%s -> %s)\n",
+				p, humanBytes(e.size), humanBytes(cur.size))
+			changed++
+		}
+	}
+	if missing > 0 || changed > 0 {
+		infof("snapshot: %d source files missing, %d changed since last run\n", missing, changed)
+	}
+	if opts.dryRun {
+		return
+	}
+	paths := make([]string, 0, len(current))
+	for p := range current {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var b strings.Builder
+	for _, p := range paths {
+		e := current[p]
+		fmt.Fprintf(&b, "%d\t%d\t%s\n", e.size, e.mtime, p)
+	}
+	if err := os.WriteFile(filepath.Join(destRoot, snapshotName), []byte(b.String()), 0o644); err != nil {
+		warnf("snapshot: %v\n", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// -sniff classifies files by their leading magic bytes instead of
+// trusting extensions. Phones and cameras produce misnamed files more
+// often than seems reasonable — HEIC shared as .jpg, extensionless
+// DCIM temp files, recovered files with .CHK names — and routing those
+// by extension sends real photos into the unsorted tree or picks the
+// wrong metadata parser. Sniffing reads the first few dozen bytes of
+// every regular file at scan time and rewrites the job's relative path
+// with the detected extension when the two disagree, so everything
+// downstream (media classification, EXIF extraction, the destination
+// name) sees the file for what it is. Note that a corrected path is a
+// different path-mode manifest key, so a file copied misnamed in an
+// earlier run is copied again once -sniff identifies it.
+
+// sniffFamily groups extensions that share a container signature, so a
+// correct-enough name isn't churned: every TIFF-based raw sniffs as
+// .tif, every ISO-BMFF video as .mp4, and rewriting those would be a
+// downgrade, not a correction.
+var sniffFamily = map[string]string{
+	".jpg": "jpeg", ".jpeg": "jpeg",
+	".tif": "tiff", ".tiff": "tiff", ".dng": "tiff", ".cr2": "tiff",
+	".nef": "tiff", ".arw": "tiff", ".orf": "tiff", ".rw2": "tiff",
+	".heic": "heif", ".heif": "heif",
+	".mp4": "bmff", ".m4v": "bmff", ".mov": "bmff", ".3gp": "bmff",
+	".insv": "bmff", ".360": "bmff", ".lrv": "bmff",
+	".mkv": "ebml", ".webm": "ebml",
+	".mpg": "mpeg", ".mpeg": "mpeg",
+}
+
+// heifBrands are the ftyp major brands that mean a still image rather
+// than a video inside an ISO-BMFF container.
+var heifBrands = map[string]string{
+	"heic": ".heic", "heix": ".heic", "hevc": ".heic",
+	"mif1": ".heic", "msf1": ".heic",
+	"avif": ".avif", "avis": ".avif",
+	"crx ": ".cr3",
+}
+
+// sniffExt returns the extension a file's content says it should have,
+// or "" when the signature isn't one copysort recognizes.
+func sniffExt(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var buf [40]byte
+	n, _ := f.Read(buf[:])
+	b := buf[:n]
+	switch {
+	case bytes.HasPrefix(b, []byte{0xff, 0xd8, 0xff}):
+		return ".jpg", nil
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")):
+		return ".png", nil
+	case bytes.HasPrefix(b, []byte("GIF87a")) || bytes.HasPrefix(b, []byte("GIF89a")):
+		return ".gif", nil
+	case bytes.HasPrefix(b, []byte("II*\x00")) || bytes.HasPrefix(b, []byte("MM\x00*")):
+		return ".tif", nil
+	case bytes.HasPrefix(b, []byte("FUJIFILMCCD-RAW")):
+		return ".raf", nil
+	case bytes.HasPrefix(b, []byte{0xff, 0x0a}):
+		return ".jxl", nil
+	case bytes.HasPrefix(b, []byte("\x00\x00\x00\x0cJXL \r\n\x87\n")):
+		return ".jxl", nil
+	case len(b) >= 12 && bytes.Equal(b[0:4], []byte("RIFF")) && bytes.Equal(b[8:12], []byte("AVI ")):
+		return ".avi", nil
+	case bytes.HasPrefix(b, []byte{0x1a, 0x45, 0xdf, 0xa3}):
+		return ".mkv", nil
+	case bytes.HasPrefix(b, []byte{0x00, 0x00, 0x01, 0xba}):
+		return ".mpg", nil
+	case len(b) >= 12 && bytes.Equal(b[4:8], []byte("ftyp")):
+		brand := string(b[8:12])
+		if ext, ok := heifBrands[brand]; ok {
+			return ext, nil
+		}
+		if brand == "qt  " {
+			return ".mov", nil
+		}
+		if strings.HasPrefix(brand, "3gp") {
+			return ".3gp", nil
+		}
+		return ".mp4", nil
+	}
+	return "", nil
+}
+
+// sniffJobs rewrites each job's extension to match its content. Archive
+// entries and symlinks are left alone: entries would need spooling just
+// to sniff, and a link has no content of its own.
+func sniffJobs(jobs []job) {
+	var fixed, named int
+	for i := range jobs {
+		j := &jobs[i]
+		if j.entryName != "" || j.symlink != "" {
+			continue
+		}
+		got, err := sniffExt(j.srcPath)
+		if err != nil || got == "" {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(j.relPath))
+		if ext == got || (ext != "" && sniffFamily[ext] != "" && sniffFamily[ext] == sniffFamily[got]) {
+			continue
+		}
+		debugf("sniff: %s is %s\n", j.relPath, got)
+		j.relPath = j.relPath[:len(j.relPath)-len(filepath.Ext(j.relPath))] + got
+		if ext == "" {
+			named++
+		} else {
+			fixed++
+		}
+	}
+	if fixed+named > 0 {
+		infof("sniff: corrected %d misnamed and %d extensionless files\n", fixed, named)
+	}
+}
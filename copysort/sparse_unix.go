@@ -0,0 +1,53 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// Sparse preservation: VM images and database files in a backup are often
+// mostly holes; copying them byte-for-byte balloons them to full logical
+// size at the destination. When a file's allocated blocks fall short of
+// its size and no stream filter is in the way, the copy walks its data
+// extents with SEEK_DATA/SEEK_HOLE and leaves the holes as holes.
+
+const (
+	seekData = 3 // SEEK_DATA
+	seekHole = 4 // SEEK_HOLE
+)
+
+// likelySparse reports whether the filesystem says the file has holes.
+func likelySparse(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Blocks*512 < st.Size
+}
+
+// copySparseTo copies in to out extent by extent, seeking over holes so
+// they stay unallocated in the destination. out must be a fresh file.
+func copySparseTo(in, out *os.File, size int64) error {
+	var off int64
+	for off < size {
+		dataStart, err := in.Seek(off, seekData)
+		if err != nil {
+			break // only a trailing hole remains
+		}
+		holeStart, err := in.Seek(dataStart, seekHole)
+		if err != nil {
+			holeStart = size
+		}
+		if _, err := in.Seek(dataStart, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := out.Seek(dataStart, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(out, in, holeStart-dataStart); err != nil {
+			return err
+		}
+		off = holeStart
+	}
+	return out.Truncate(size)
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openSource opens a source file for reading with O_NOATIME, so a bulk
+// import doesn't rewrite access times across the whole card. The flag
+// is only honored for files the caller owns; a refusal falls back to a
+// plain open.
+func openSource(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOATIME, 0)
+	if errors.Is(err, syscall.EPERM) {
+		return os.Open(path)
+	}
+	return f, err
+}
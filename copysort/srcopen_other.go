@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// openSource opens a source file for reading. O_NOATIME is a Linux-only
+// nicety; elsewhere this is a plain open.
+func openSource(path string) (*os.File, error) {
+	return os.Open(path)
+}
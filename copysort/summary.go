@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// printBreakdown expands the single-line summary: counts and bytes per
+// extension category, per destination year/month, and how many media
+// files had to fall back to filename or mtime dating because embedded
+// metadata was missing — the files most likely to be sitting in the
+// wrong folder.
+func printBreakdown(st *stats) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.byCategory) == 0 {
+		return
+	}
+	fmt.Println("by type:")
+	for _, k := range sortedKeys(st.byCategory) {
+		t := st.byCategory[k]
+		fmt.Printf("  %-8s %6d files  %s\n", k, t.files, humanBytes(t.bytes))
+	}
+	if len(st.byMonth) > 0 {
+		fmt.Println("by month:")
+		for _, k := range sortedKeys(st.byMonth) {
+			t := st.byMonth[k]
+			fmt.Printf("  %-8s %6d files  %s\n", k, t.files, humanBytes(t.bytes))
+		}
+	}
+	if len(st.byWorker) > 1 {
+		fmt.Println("by worker:")
+		for id, w := range st.byWorker {
+			fmt.Printf("  #%-7d %6d files  %s  %s\n", id, w.files, humanBytes(w.bytes), busyRate(w))
+		}
+	}
+	if len(st.bySource) > 1 {
+		fmt.Println("by source:")
+		keys := make([]string, 0, len(st.bySource))
+		for k := range st.bySource {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := st.bySource[k]
+			fmt.Printf("  %6d files  %s  %s  %s\n", s.files, humanBytes(s.bytes), busyRate(*s), k)
+		}
+	}
+	if len(st.dupes) > 0 {
+		var total tally
+		for _, t := range st.dupes {
+			total.files += t.files
+			total.bytes += t.bytes
+		}
+		fmt.Printf("duplicates: %d files already archived, %s of destination space saved\n",
+			total.files, humanBytes(total.bytes))
+		if len(st.dupes) > 1 {
+			for _, k := range sortedKeys(st.dupes) {
+				t := st.dupes[k]
+				fmt.Printf("  %6d files  %s  %s\n", t.files, humanBytes(t.bytes), k)
+			}
+		}
+	}
+	if n := st.byDateSource[dateFromFilename] + st.byDateSource[dateFromModTime]; n > 0 {
+		fmt.Printf("dates: %d from metadata, %d from filename, %d from mtime (no embedded date)\n",
+			st.byDateSource[dateFromExif]+st.byDateSource[dateFromVideo],
+			st.byDateSource[dateFromFilename], st.byDateSource[dateFromModTime])
+	}
+	if opts.reportFallbacks && len(st.fallbacks) > 0 {
+		fmt.Println("fallback dates (review for mis-sorted files):")
+		sort.Slice(st.fallbacks, func(a, b int) bool {
+			return st.fallbacks[a].srcPath < st.fallbacks[b].srcPath
+		})
+		for _, f := range st.fallbacks {
+			fmt.Printf("  %-8s %s  %s\n", f.method, f.date.Format("2006-01-02 15:04:05"), f.srcPath)
+		}
+	}
+}
+
+// busyRate renders a busyTally's throughput over the time its files
+// actually spent being processed, not the whole run.
+func busyRate(b busyTally) string {
+	if b.busy <= 0 {
+		return "-"
+	}
+	return humanBytes(int64(float64(b.bytes)/b.busy.Seconds())) + "/s"
+}
+
+func sortedKeys(m map[string]*tally) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// systemStressed reports whether the machine would rather not run a
+// full set of copy workers right now: discharging battery, or a load
+// average well past the CPU count.
+
+func systemStressed() (bool, string) {
+	if onBattery() {
+		return true, "on battery"
+	}
+	if load, high := loadHigh(); high {
+		return true, fmt.Sprintf("load average %.1f", load)
+	}
+	return false, ""
+}
+
+func onBattery() bool {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*/status")
+	for _, p := range supplies {
+		b, err := os.ReadFile(p)
+		if err == nil && strings.TrimSpace(string(b)) == "Discharging" {
+			return true
+		}
+	}
+	return false
+}
+
+func loadHigh() (float64, bool) {
+	b, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, false
+	}
+	field, _, _ := strings.Cut(string(b), " ")
+	load, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return 0, false
+	}
+	return load, load > 2*float64(runtime.NumCPU())
+}
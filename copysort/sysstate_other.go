@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// systemStressed has no probe on this platform; -auto-throttle never
+// engages.
+func systemStressed() (bool, string) {
+	return false, ""
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tar destination mode: -tar single|year streams the sorted output into
+// tar archives under the destination root instead of a directory tree,
+// for cold-storage uploads where millions of small files are
+// impractical. "single" produces one copysort.tar; "year" produces one
+// archive per capture year (plus unsorted.tar for the generic tree).
+// Each archive gets a .idx sidecar listing its member paths, and the
+// manifest records "archive.tar:member" so repeat runs still skip.
+// Existing archives are never reopened — tar appends are fragile — so a
+// second run into the same destination numbers its archives _2, _3, …
+
+type tarFile struct {
+	f   *os.File
+	tw  *tar.Writer
+	idx *os.File
+}
+
+var tarDest struct {
+	mu      sync.Mutex
+	mode    string // "", "single" or "year"
+	root    string
+	open    map[string]*tarFile // archive base name -> open writer
+	members map[string]bool     // "base/member" claims within the run
+}
+
+func initTar(mode, destRoot string) error {
+	switch mode {
+	case "":
+		return nil
+	case "single", "year":
+	default:
+		return fmt.Errorf("invalid -tar %q (want single or year)", mode)
+	}
+	for flagName, set := range map[string]bool{
+		"-mirror":            opts.mirror,
+		"-albums":            opts.albums != "",
+		"-convert":           opts.convert != "",
+		"-video-thumbs":      opts.videoThumbs != "",
+		"-phash":             opts.phash,
+		"-dupe-policy":       opts.dupePolicy != "",
+		"-strip-gps":         opts.stripGPS,
+		"-encrypt":           opts.encrypt != "",
+		"-compress":          opts.compress != "",
+		"-preserve-symlinks": opts.preserveSymlinks,
+	} {
+		if set {
+			return fmt.Errorf("%s needs a directory destination, not -tar", flagName)
+		}
+	}
+	tarDest.mode = mode
+	tarDest.root = destRoot
+	tarDest.open = map[string]*tarFile{}
+	tarDest.members = map[string]bool{}
+	return nil
+}
+
+// tarBase picks the archive a file belongs in.
+func tarBase(dateSource string, when time.Time) string {
+	if tarDest.mode == "single" {
+		return "copysort"
+	}
+	if dateSource == "" {
+		return "unsorted"
+	}
+	return fmt.Sprintf("%04d", when.Year())
+}
+
+// tarOpen returns the writer for an archive base name, creating the
+// archive and its index on first use. Caller holds tarDest.mu.
+func tarOpen(base string) (*tarFile, error) {
+	if t := tarDest.open[base]; t != nil {
+		return t, nil
+	}
+	name := filepath.Join(tarDest.root, base+".tar")
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			break
+		}
+		name = filepath.Join(tarDest.root, fmt.Sprintf("%s_%d.tar", base, n))
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := os.OpenFile(name+".idx", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t := &tarFile{f: f, tw: tar.NewWriter(f), idx: idx}
+	tarDest.open[base] = t
+	return t, nil
+}
+
+// tarCopy streams one job into its archive and records it, taking the
+// place of the claim/copy path of processJob. Tar streams are
+// sequential, so all of this runs under one lock; the workers upstream
+// still parallelize the scanning and metadata extraction.
+func tarCopy(j *job, readPath, destRel, dateSource string, when time.Time, month string, m *manifest, st *stats) {
+	tarDest.mu.Lock()
+	defer tarDest.mu.Unlock()
+
+	base := tarBase(dateSource, when)
+	member := destRel
+	for n := 2; tarDest.members[base+"/"+member]; n++ {
+		ext := path.Ext(destRel)
+		member = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(destRel, ext), n, ext)
+	}
+	tarDest.members[base+"/"+member] = true
+
+	if opts.dryRun {
+		debugf("would add %s -> %s.tar:%s\n", j.srcPath, base, member)
+		st.copied.Add(1)
+		return
+	}
+	t, err := tarOpen(base)
+	if err != nil {
+		errorf("%s: %v\n", j.srcPath, err)
+		st.fail()
+		return
+	}
+	capture := time.Time{}
+	if opts.mtime == "capture" && dateSource != "" {
+		capture = when
+	}
+	sum, err := tarAdd(t, readPath, member, capture)
+	if err != nil {
+		errorf("%s: %v\n", j.srcPath, err)
+		st.fail()
+		return
+	}
+	st.addSum(sum, base+".tar:"+member)
+	if err := m.append(m.key(j), base+".tar:"+member, j.size, sum); err != nil {
+		errorf("manifest: %v\n", err)
+		st.fail()
+		return
+	}
+	st.copied.Add(1)
+	st.bytes.Add(j.size)
+	st.record(category(j.relPath), month, dateSource, j.size)
+	debugf("add %s -> %s.tar:%s\n", j.srcPath, base, member)
+
+	// Companions ride along under the primary's stem, same as in the
+	// directory tree.
+	stem := strings.TrimSuffix(member, path.Ext(member))
+	for _, c := range j.companions {
+		if m.has(m.key(c)) && m.fresh(c) {
+			st.skipped.Add(1)
+			recordSkip(c.srcPath, "in manifest")
+			continue
+		}
+		cm := stem + path.Ext(c.relPath)
+		tarDest.members[base+"/"+cm] = true
+		// An archive-entry companion has no file of its own — srcPath is
+		// the containing archive — so spool it out first, like the primary.
+		cRead := c.srcPath
+		if c.entryName != "" {
+			spool, err := spoolEntry(c)
+			if err != nil {
+				errorf("%s: %v\n", c.srcPath, err)
+				st.fail()
+				continue
+			}
+			cRead = spool
+		}
+		sum, err := tarAdd(t, cRead, cm, capture)
+		if cRead != c.srcPath {
+			os.Remove(cRead)
+		}
+		if err != nil {
+			errorf("%s: %v\n", c.srcPath, err)
+			st.fail()
+			continue
+		}
+		st.addSum(sum, base+".tar:"+cm)
+		if err := m.append(m.key(c), base+".tar:"+cm, c.size, sum); err != nil {
+			errorf("manifest: %v\n", err)
+			st.fail()
+			continue
+		}
+		st.copied.Add(1)
+		st.bytes.Add(c.size)
+		st.record(category(c.relPath), month, "", c.size)
+	}
+}
+
+// tarAdd streams one file into an open archive and its index, returning
+// the content hash when -sha256sums asked for one. A zero capture time
+// keeps the source mtime on the member.
+func tarAdd(t *tarFile, readPath, member string, capture time.Time) (string, error) {
+	in, err := os.Open(readPath)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+	mtime := info.ModTime()
+	if !capture.IsZero() {
+		mtime = capture
+	}
+	hdr := &tar.Header{
+		Name:    member,
+		Mode:    0o644,
+		Size:    info.Size(),
+		ModTime: mtime,
+	}
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return "", err
+	}
+	var out io.Writer = t.tw
+	var h = sha256.New()
+	if hashingCopies() {
+		out = io.MultiWriter(t.tw, h)
+	}
+	buf := getBuf()
+	_, err = io.CopyBuffer(out, in, buf)
+	putBuf(buf)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fmt.Fprintf(t.idx, "%s\n", member); err != nil {
+		return "", err
+	}
+	if hashingCopies() {
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	return "", nil
+}
+
+// closeTars finishes every open archive; an unterminated tar is useless,
+// so close failures count as run errors.
+func closeTars(st *stats) {
+	tarDest.mu.Lock()
+	defer tarDest.mu.Unlock()
+	for base, t := range tarDest.open {
+		if err := t.tw.Close(); err != nil {
+			errorf("%s.tar: %v\n", base, err)
+			st.fail()
+		}
+		if opts.fsync {
+			t.f.Sync()
+		}
+		if err := t.f.Close(); err != nil {
+			errorf("%s.tar: %v\n", base, err)
+			st.fail()
+		}
+		t.idx.Close()
+	}
+	tarDest.open = map[string]*tarFile{}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Adaptive throttling: -auto-throttle watches the machine every few
+// seconds and shrinks the effective worker count to one while it is on
+// battery or under heavy CPU load, ramping back to -workers once mains
+// power or idle time returns. Workers finish their current file before
+// the new limit bites, same as the pause gate. What "stressed" means is
+// platform-specific (battery and load average on linux; elsewhere the
+// probe reports calm and the flag is a no-op).
+
+const throttleInterval = 5 * time.Second
+
+var throttle struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int
+	inUse int
+}
+
+func initThrottle(workers int) {
+	throttle.limit = workers
+	if opts.autoWorkers {
+		throttle.limit = opts.minWorkers
+	}
+	throttle.cond = sync.NewCond(&throttle.mu)
+	if !opts.autoThrottle {
+		return
+	}
+	go func() {
+		throttled := false
+		for range time.Tick(throttleInterval) {
+			stressed, why := systemStressed()
+			switch {
+			case stressed && !throttled:
+				throttled = true
+				infof("throttling to 1 worker (%s)\n", why)
+				setWorkerLimit(1)
+			case !stressed && throttled:
+				throttled = false
+				infof("restoring %d workers\n", workers)
+				setWorkerLimit(workers)
+			}
+		}
+	}()
+}
+
+// setWorkerLimit changes how many workers may run at once; raising it
+// wakes anyone parked in throttleAcquire.
+func setWorkerLimit(n int) {
+	throttle.mu.Lock()
+	if n > throttle.limit {
+		throttle.cond.Broadcast()
+	}
+	throttle.limit = n
+	throttle.mu.Unlock()
+}
+
+// throttleAcquire blocks until a worker slot is available under the
+// current limit; every acquire is paired with a throttleRelease.
+func throttleAcquire() {
+	throttle.mu.Lock()
+	for throttle.inUse >= throttle.limit {
+		throttle.cond.Wait()
+	}
+	throttle.inUse++
+	throttle.mu.Unlock()
+}
+
+func throttleRelease() {
+	throttle.mu.Lock()
+	throttle.inUse--
+	throttle.mu.Unlock()
+	throttle.cond.Signal()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Video poster frames: -video-thumbs generates a small JPEG next to each
+// sorted video via ffmpeg (video.MOV -> video.MOV.jpg), so the archive
+// is browsable on devices that can't thumbnail .MOV/.AVI themselves.
+
+var thumbs struct {
+	exts map[string]bool // nil when disabled; empty set means all videos
+}
+
+// initThumbs parses -video-thumbs: "all" or a comma list like "mov,avi".
+func initThumbs(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("-video-thumbs: %w", err)
+	}
+	if opts.encrypt != "" {
+		return fmt.Errorf("-video-thumbs: cannot thumbnail encrypted destinations")
+	}
+	thumbs.exts = map[string]bool{}
+	if spec == "all" {
+		return nil
+	}
+	for _, e := range strings.Split(spec, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if !videoExts[e] {
+			return fmt.Errorf("-video-thumbs: %s is not a sortable video extension", e)
+		}
+		thumbs.exts[e] = true
+	}
+	return nil
+}
+
+func wantThumb(ext string) bool {
+	if thumbs.exts == nil || !videoExts[ext] {
+		return false
+	}
+	return len(thumbs.exts) == 0 || thumbs.exts[ext]
+}
+
+// makeThumb writes destPath.jpg with a scaled first frame. Thumbnail
+// failures are reported but don't fail the copy — the video itself is
+// safely archived either way.
+func makeThumb(destPath string, st *stats) {
+	out := destPath + ".jpg"
+	cmd := exec.Command("ffmpeg", "-y", "-loglevel", "error",
+		"-i", destPath, "-frames:v", "1", "-vf", "scale=320:-2", out)
+	var errb strings.Builder
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(errb.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		errorf("thumbnail %s: %s\n", destPath, msg)
+		st.fail()
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Interactive TUI (-tui): a bubbletea view with per-worker activity, a
+// scrolling file log, a live throughput sparkline, and pause/resume
+// keybindings — the plain progress bar can't show what each of the 8
+// workers is doing. Events flow in from the workers over a channel; when
+// the TUI is off every entry point is a cheap no-op.
+
+// pauseGate lets the UI (or a signal) stop all workers after their
+// current file and release them again on demand.
+type pauseGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+}
+
+func newPauseGate() *pauseGate {
+	g := &pauseGate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+func (g *pauseGate) wait() {
+	g.mu.Lock()
+	for g.paused {
+		g.cond.Wait()
+	}
+	g.mu.Unlock()
+}
+
+func (g *pauseGate) setPaused(p bool) {
+	g.mu.Lock()
+	g.paused = p
+	g.mu.Unlock()
+	g.cond.Broadcast()
+}
+
+var gate = newPauseGate()
+
+type (
+	evStart struct {
+		worker int
+		path   string
+	}
+	evDone struct {
+		worker int
+		bytes  int64
+	}
+	evLog  struct{ line string }
+	evTick time.Time
+)
+
+var tui struct {
+	prog   *tea.Program
+	events chan tea.Msg
+	done   chan struct{}
+}
+
+func tuiActive() bool { return tui.prog != nil }
+
+func uiFileStart(worker int, path string) {
+	if tuiActive() {
+		tui.events <- evStart{worker, path}
+	}
+}
+
+func uiFileDone(worker int, bytes int64) {
+	if tuiActive() {
+		tui.events <- evDone{worker, bytes}
+	}
+}
+
+func startTUI(total int) {
+	m := &tuiModel{
+		total:   total,
+		workers: make([]string, opts.workers),
+		started: time.Now(),
+	}
+	tui.events = make(chan tea.Msg, 256)
+	tui.done = make(chan struct{})
+	tui.prog = tea.NewProgram(m)
+	go func() {
+		for ev := range tui.events {
+			tui.prog.Send(ev)
+		}
+	}()
+	go func() {
+		tui.prog.Run()
+		close(tui.done)
+	}()
+}
+
+func stopTUI() {
+	if !tuiActive() {
+		return
+	}
+	tui.prog.Quit()
+	<-tui.done
+	tui.prog = nil
+}
+
+type tuiModel struct {
+	total    int
+	count    int
+	bytes    int64
+	lastSamp int64
+	started  time.Time
+	workers  []string
+	log      []string
+	rates    []float64 // bytes/sec samples for the sparkline
+	paused   bool
+	detached bool
+}
+
+func (m *tuiModel) Init() tea.Cmd { return tickCmd() }
+
+func tickCmd() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return evTick(t) })
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case evStart:
+		if msg.worker < len(m.workers) {
+			m.workers[msg.worker] = msg.path
+		}
+	case evDone:
+		if msg.worker < len(m.workers) {
+			m.workers[msg.worker] = ""
+		}
+		m.count++
+		m.bytes += msg.bytes
+	case evLog:
+		m.log = append(m.log, msg.line)
+		if len(m.log) > 10 {
+			m.log = m.log[len(m.log)-10:]
+		}
+	case evTick:
+		m.rates = append(m.rates, float64(m.bytes-m.lastSamp))
+		m.lastSamp = m.bytes
+		if len(m.rates) > 40 {
+			m.rates = m.rates[len(m.rates)-40:]
+		}
+		return m, tickCmd()
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "p":
+			m.paused = true
+			gate.setPaused(true)
+		case "r":
+			m.paused = false
+			gate.setPaused(false)
+		case "q", "ctrl+c":
+			// Detach the view; the run itself keeps going.
+			m.detached = true
+			gate.setPaused(false)
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+var sparkRunes = []rune(" ▁▂▃▄▅▆▇█")
+
+func (m *tuiModel) View() string {
+	if m.detached {
+		return ""
+	}
+	var b strings.Builder
+	state := "running"
+	if m.paused {
+		state = "PAUSED (press r to resume)"
+	}
+	elapsed := time.Since(m.started).Round(time.Second)
+	fmt.Fprintf(&b, "copysort  %d/%d files  %s  %s  [%s]\n\n",
+		m.count, m.total, humanBytes(m.bytes), elapsed, state)
+	var peak float64
+	for _, r := range m.rates {
+		if r > peak {
+			peak = r
+		}
+	}
+	spark := make([]rune, len(m.rates))
+	for i, r := range m.rates {
+		idx := 0
+		if peak > 0 {
+			idx = int(r / peak * float64(len(sparkRunes)-1))
+		}
+		spark[i] = sparkRunes[idx]
+	}
+	cur := float64(0)
+	if len(m.rates) > 0 {
+		cur = m.rates[len(m.rates)-1]
+	}
+	fmt.Fprintf(&b, "  %s %s/s\n\n", string(spark), humanBytes(int64(cur)))
+	for i, w := range m.workers {
+		if w == "" {
+			w = "idle"
+		}
+		fmt.Fprintf(&b, "  worker %d: %s\n", i, w)
+	}
+	if len(m.log) > 0 {
+		b.WriteString("\n")
+		for _, l := range m.log {
+			fmt.Fprintf(&b, "  %s\n", l)
+		}
+	}
+	b.WriteString("\n  p pause · r resume · q detach\n")
+	return b.String()
+}
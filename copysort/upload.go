@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Immich upload: -upload immich:URL pushes every newly sorted media file
+// to an Immich server via its REST API, alongside the local archive. The
+// capture date copysort already extracted is sent as the asset date, and
+// the file's top-level source folder becomes its album. The manifest makes
+// this resumable for free: files skipped as already-imported are never
+// re-uploaded. The API key comes from $IMMICH_API_KEY.
+
+var uploader struct {
+	base string // server URL without trailing slash
+	key  string
+
+	mu     sync.Mutex
+	albums map[string]string // album name -> id, lazily fetched
+}
+
+func initUpload(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	rest, ok := strings.CutPrefix(spec, "immich:")
+	if !ok {
+		return fmt.Errorf("invalid -upload %q (want immich:URL)", spec)
+	}
+	u, err := url.Parse(rest)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid -upload server URL %q", rest)
+	}
+	key := os.Getenv("IMMICH_API_KEY")
+	if key == "" {
+		return fmt.Errorf("-upload: IMMICH_API_KEY is not set")
+	}
+	uploader.base = strings.TrimSuffix(rest, "/")
+	uploader.key = key
+	return nil
+}
+
+// uploadAsset sends one media file to the server and files it under the
+// album named after its top-level source folder, if it has one.
+func uploadAsset(j *job, readPath string, when time.Time) error {
+	f, err := os.Open(readPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// The multipart body streams through a pipe into the request, so a
+	// multi-gigabyte video is never held in memory.
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		mw.WriteField("deviceAssetId", j.relPath)
+		mw.WriteField("deviceId", "copysort")
+		mw.WriteField("fileCreatedAt", when.Format(time.RFC3339))
+		mw.WriteField("fileModifiedAt", j.mtime.Format(time.RFC3339))
+		part, err := mw.CreateFormFile("assetData", path.Base(j.relPath))
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(part, f); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := apiCall("POST", "/api/assets", mw.FormDataContentType(), pr, &resp); err != nil {
+		pr.CloseWithError(err) // unblock the writer if the request died early
+		return err
+	}
+	if dir := strings.Split(j.relPath, "/")[0]; strings.Contains(j.relPath, "/") {
+		return albumAdd(dir, resp.ID)
+	}
+	return nil
+}
+
+// albumAdd puts an asset in the named album, creating it on first use.
+func albumAdd(name, assetID string) error {
+	uploader.mu.Lock()
+	defer uploader.mu.Unlock()
+	if uploader.albums == nil {
+		var existing []struct {
+			ID        string `json:"id"`
+			AlbumName string `json:"albumName"`
+		}
+		if err := apiCall("GET", "/api/albums", "", nil, &existing); err != nil {
+			return err
+		}
+		uploader.albums = map[string]string{}
+		for _, a := range existing {
+			uploader.albums[a.AlbumName] = a.ID
+		}
+	}
+	id, ok := uploader.albums[name]
+	if !ok {
+		req, _ := json.Marshal(map[string]string{"albumName": name})
+		var created struct {
+			ID string `json:"id"`
+		}
+		if err := apiCall("POST", "/api/albums", "application/json", bytes.NewReader(req), &created); err != nil {
+			return err
+		}
+		id = created.ID
+		uploader.albums[name] = id
+	}
+	req, _ := json.Marshal(map[string][]string{"ids": {assetID}})
+	return apiCall("PUT", "/api/albums/"+id+"/assets", "application/json", bytes.NewReader(req), nil)
+}
+
+func apiCall(method, endpoint, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequest(method, uploader.base+endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", uploader.key)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("immich: %s %s: %s: %s", method, endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// io_uring is a linux facility; elsewhere -iouring is a configuration
+// error caught at startup, so these stubs never run.
+
+const uringAvailable = false
+
+func uringSupported() bool { return false }
+
+func uringCopy(src, dst *os.File, size int64) error {
+	return errors.New("io_uring is only supported on linux")
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// Volume shadow copies are a Windows facility; elsewhere -vss is a
+// configuration error.
+func vssSetup(srcRoots []string) ([]string, func(), error) {
+	return nil, nil, errors.New("-vss is only supported on windows")
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// No xattr syscall in the stdlib here; -xattr-sums is a configuration
+// error.
+
+const xattrSumsOK = false
+
+func setXattrSum(path, sum string) error {
+	return errors.New("xattrs are not supported on this platform")
+}